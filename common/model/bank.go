@@ -0,0 +1,9 @@
+package model
+
+const (
+	// Bank account purpose
+	BankAccountFxPosition      = "FXP"
+	BankAccountFeeIncome       = "FEE"
+	BankAccountInterestExpense = "INT"
+	BankAccountInterestIncome  = "INC"
+)
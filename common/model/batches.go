@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Maximum number of legs in a batch
+	BatchMaxLegs = 1000
+)
+
+// Batch moves money between several services all-or-nothing, its state uses
+// the transaction states and applies to every leg.
+type Batch struct {
+	Id            uuid.UUID
+	State         string
+	Time          string
+	Legs          []Transaction
+	FailureCode   string
+	FailureReason string
+}
+
+func NewBatch(legs []Transaction) (Batch, error) {
+	if len(legs) == 0 {
+		return Batch{}, errors.New("batch needs at least one leg")
+	}
+	if len(legs) > BatchMaxLegs {
+		return Batch{}, fmt.Errorf("batch can't have more than %d legs", BatchMaxLegs)
+	}
+
+	newBatch := Batch{
+		State: TransactionStateProcessing,
+		Time:  "NOW",
+		Legs:  legs,
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Batch{}, err
+	}
+	newBatch.Id = id
+
+	for i := range newBatch.Legs {
+		newBatch.Legs[i].Batch = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	return newBatch, nil
+}
+
+// Fail marks the batch and every leg as failed, the leg at index failed gets
+// the actual reason and the rest are marked as aborted.
+func (batch *Batch) Fail(failed int, err *TransactionError) {
+	batch.State = TransactionStateError
+	batch.FailureCode = err.Code
+	batch.FailureReason = fmt.Sprintf("leg %d: %s", failed, err.Message)
+
+	aborted := NewTransactionError(TransactionFailureBatchAborted,
+		"batch %s was aborted by leg %d", batch.Id, failed)
+	for i := range batch.Legs {
+		if i == failed {
+			batch.Legs[i].Fail(err)
+		} else {
+			batch.Legs[i].Fail(aborted)
+		}
+	}
+}
+
+func (batch *Batch) Succeed() {
+	batch.State = TransactionStateSuccess
+	for i := range batch.Legs {
+		batch.Legs[i].State = TransactionStateSuccess
+	}
+}
+
+// Sources returns every distinct source service of the batch.
+func (batch *Batch) Sources() []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	sources := make([]uuid.UUID, 0)
+	for _, leg := range batch.Legs {
+		if !seen[leg.Source] {
+			seen[leg.Source] = true
+			sources = append(sources, leg.Source)
+		}
+	}
+	return sources
+}
+
+// Services returns every service touched by the batch, sources and
+// destinations alike.
+func (batch *Batch) Services() []uuid.UUID {
+	services := make([]uuid.UUID, 0, 2*len(batch.Legs))
+	for _, leg := range batch.Legs {
+		services = append(services, leg.Source, leg.Destination)
+	}
+	return services
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Billing cycle state
+	BillingCycleStateOpen   = "OPN"
+	BillingCycleStateClosed = "CLS"
+
+	// Longest time between the end of a cycle and its due date, the due date
+	// always falls before the next cycle ends
+	CreditMaxGraceDays = 27
+)
+
+var ErrCreditNotAllowed = errors.New("service is not a line of credit")
+
+// CreditLine lets a LOC service owe up to Limit. Rate is the yearly
+// percentage charged on carried balances, the minimum payment of a statement
+// is MinimumRate percent of it but never less than MinimumAmount. Statements
+// are due GraceDays after their cycle ends.
+type CreditLine struct {
+	Service       uuid.UUID
+	Limit         decimal.Decimal
+	Rate          decimal.Decimal
+	MinimumRate   decimal.Decimal
+	MinimumAmount decimal.Decimal
+	GraceDays     int
+}
+
+// BillingCycle is a calendar month of a line of credit, the statement is
+// issued when it closes. Owed amounts are positive.
+type BillingCycle struct {
+	Id         uuid.UUID
+	Service    uuid.UUID
+	Start      time.Time
+	End        time.Time
+	Due        time.Time
+	State      string
+	Statement  decimal.Decimal
+	MinimumDue decimal.Decimal
+	Interest   decimal.Decimal
+	Payments   decimal.Decimal
+	LateFee    decimal.Decimal
+	Late       bool
+	Assessed   bool
+}
+
+// Billing is the state of a line of credit, Statement is the last cycle
+// that was closed and has a zero id if there is none.
+type Billing struct {
+	Line      CreditLine
+	Owed      decimal.Decimal
+	Available decimal.Decimal
+	Current   BillingCycle
+	Statement BillingCycle
+}
+
+func NewCreditLine(
+	service uuid.UUID,
+	limit, rate, minimumRate, minimumAmount decimal.Decimal,
+	graceDays int,
+) (CreditLine, error) {
+	newLine := CreditLine{
+		Service:       service,
+		Limit:         limit,
+		Rate:          rate,
+		MinimumRate:   minimumRate,
+		MinimumAmount: minimumAmount,
+		GraceDays:     graceDays,
+	}
+
+	if err := newLine.Validate(); err != nil {
+		return CreditLine{}, err
+	}
+
+	return newLine, nil
+}
+
+func (line *CreditLine) Validate() error {
+	if line.Limit.IsNegative() || line.Limit.Exponent() < -2 {
+		return errors.New("credit limit can't be negative and can have at most 2 decimals")
+	}
+
+	hundred := decimal.NewFromInt(100)
+	if line.Rate.IsNegative() || line.Rate.GreaterThan(hundred) ||
+		line.MinimumRate.IsNegative() || line.MinimumRate.GreaterThan(hundred) {
+		return errors.New("credit rates must be between 0% and 100%")
+	}
+
+	if line.MinimumAmount.IsNegative() || line.MinimumAmount.Exponent() < -2 {
+		return errors.New("minimum payment can't be negative and can have at most 2 decimals")
+	}
+
+	if line.GraceDays <= 0 || line.GraceDays > CreditMaxGraceDays {
+		return errors.New("grace days must be between 1 and 27")
+	}
+
+	return nil
+}
+
+// MinimumPayment is the least that has to be paid of a statement.
+func (line *CreditLine) MinimumPayment(statement decimal.Decimal) decimal.Decimal {
+	if !statement.IsPositive() {
+		return decimal.Zero
+	}
+
+	minimum := decimal.Max(line.MinimumAmount,
+		statement.Mul(line.MinimumRate).Div(decimal.NewFromInt(100)).RoundUp(2))
+	return decimal.Min(minimum, statement)
+}
+
+// Interest is what carrying an average daily balance of owed for days costs.
+func (line *CreditLine) Interest(owed decimal.Decimal, days int) decimal.Decimal {
+	if !owed.IsPositive() {
+		return decimal.Zero
+	}
+
+	return owed.Mul(line.Rate).Mul(decimal.NewFromInt(int64(days))).
+		Div(decimal.NewFromInt(100 * 365)).Round(2)
+}
+
+// NewBillingCycle opens the cycle that runs from start to the end of its
+// month.
+func NewBillingCycle(service uuid.UUID, start time.Time) (BillingCycle, error) {
+	newCycle := BillingCycle{
+		Service:    service,
+		Start:      start,
+		End:        time.Date(start.Year(), start.Month()+1, 0, 0, 0, 0, 0, time.UTC),
+		State:      BillingCycleStateOpen,
+		Statement:  decimal.Zero,
+		MinimumDue: decimal.Zero,
+		Interest:   decimal.Zero,
+		Payments:   decimal.Zero,
+		LateFee:    decimal.Zero,
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return BillingCycle{}, err
+	}
+	newCycle.Id = id
+
+	return newCycle, nil
+}
+
+// Days is the length of the cycle.
+func (cycle *BillingCycle) Days() int {
+	return int(cycle.End.Sub(cycle.Start).Hours()/24) + 1
+}
+
+// Close issues the statement of the cycle, statement already includes the
+// interest of the cycle.
+func (cycle *BillingCycle) Close(line CreditLine, statement, interest decimal.Decimal) {
+	cycle.State = BillingCycleStateClosed
+	cycle.Statement = decimal.Max(statement, decimal.Zero)
+	cycle.Interest = interest
+	cycle.MinimumDue = line.MinimumPayment(cycle.Statement)
+	cycle.Due = cycle.End.AddDate(0, 0, line.GraceDays)
+}
+
+// PaidInFull tells if the statement was paid before it was due, carried
+// balances only pay interest when the previous statement wasn't.
+func (cycle *BillingCycle) PaidInFull() bool {
+	return !cycle.Payments.LessThan(cycle.Statement)
+}
+
+// Remaining is what is left of the minimum payment.
+func (cycle *BillingCycle) Remaining() decimal.Decimal {
+	return decimal.Max(cycle.MinimumDue.Sub(cycle.Payments), decimal.Zero)
+}
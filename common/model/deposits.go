@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Maturity instruction
+	DepositInstructionRollover = "ROL"
+	DepositInstructionPayout   = "PAY"
+
+	// Deposit state
+	DepositStateOpen    = "OPN"
+	DepositStateMatured = "MAT"
+
+	// Longest term of a deposit in months
+	DepositMaxTerm = 120
+)
+
+var (
+	ErrDepositNotAllowed = errors.New("service is not a certificate of deposit")
+	ErrDepositOpen       = errors.New("deposit term has not matured")
+	ErrDepositPayout     = errors.New("deposit can't be paid out to the service")
+)
+
+// Deposit is the term of a COD service, debits before Maturity need the
+// early withdrawal penalty to be accepted. On maturity the deposit either
+// starts a new term of the same length or its balance is sent to Payout.
+type Deposit struct {
+	Service     uuid.UUID
+	Term        int
+	Start       time.Time
+	Maturity    time.Time
+	Instruction string
+	Payout      uuid.NullUUID
+	State       string
+}
+
+// NewDeposit starts a term of term months today.
+func NewDeposit(
+	service uuid.UUID,
+	term int,
+	instruction string,
+	payout uuid.NullUUID,
+) (Deposit, error) {
+	start := Today()
+	newDeposit := Deposit{
+		Service:     service,
+		Term:        term,
+		Start:       start,
+		Maturity:    addMonths(start, term),
+		Instruction: instruction,
+		Payout:      payout,
+		State:       DepositStateOpen,
+	}
+
+	if err := newDeposit.Validate(); err != nil {
+		return Deposit{}, err
+	}
+
+	return newDeposit, nil
+}
+
+func (deposit *Deposit) Validate() error {
+	if deposit.Term <= 0 || deposit.Term > DepositMaxTerm {
+		return fmt.Errorf("deposit term must be between 1 and %d months", DepositMaxTerm)
+	}
+
+	return deposit.ValidateInstruction()
+}
+
+// ValidateInstruction checks what happens on maturity, only payouts have a
+// payout service.
+func (deposit *Deposit) ValidateInstruction() error {
+	switch deposit.Instruction {
+	case DepositInstructionRollover:
+		if deposit.Payout.Valid {
+			return errors.New("rolled over deposits are not paid out")
+		}
+	case DepositInstructionPayout:
+		if !deposit.Payout.Valid {
+			return errors.New("deposit payout needs a service")
+		}
+		if deposit.Payout.UUID == deposit.Service {
+			return errors.New("deposit can't be paid out to itself")
+		}
+	default:
+		return fmt.Errorf("unknown maturity instruction %q", deposit.Instruction)
+	}
+
+	return nil
+}
+
+// Matured tells if the term is over on day, matured deposits can be debited
+// without penalty.
+func (deposit *Deposit) Matured(day time.Time) bool {
+	return deposit.State == DepositStateMatured || !day.Before(deposit.Maturity)
+}
+
+// Rollover starts the next term on the day the current one matured.
+func (deposit *Deposit) Rollover() {
+	deposit.Start = deposit.Maturity
+	deposit.Maturity = addMonths(deposit.Start, deposit.Term)
+}
+
+// CanPayout tells if the balance of a deposit can be sent to payout on
+// maturity.
+func (srv *Service) CanPayout(payout Service) bool {
+	return payout.Currency == srv.Currency &&
+		(payout.Type == ServiceTypeSavings || payout.Type == ServiceTypeChequing)
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Fee kind
+	FeeKindTransfer          = "TRF"
+	FeeKindMaintenance       = "MNT"
+	FeeKindOverdraft         = "OVD"
+	FeeKindInsufficientFunds = "NSF"
+	FeeKindLate              = "LTE"
+	FeeKindEarlyWithdrawal   = "EWD"
+)
+
+// FeeRule is what services of a type and currency are charged for kind, Rate
+// is a percentage of the transferred, overdue or withdrawn amount and is only
+// allowed on transfer, late and early withdrawal fees.
+type FeeRule struct {
+	Id          uuid.UUID
+	ServiceType string
+	Kind        string
+	Currency    string
+	Amount      decimal.Decimal
+	Rate        decimal.Decimal
+}
+
+func NewFeeRule(
+	serviceType, kind, currency string,
+	amount, rate decimal.Decimal,
+) (FeeRule, error) {
+	newRule := FeeRule{
+		ServiceType: serviceType,
+		Kind:        kind,
+		Currency:    currency,
+		Amount:      amount,
+		Rate:        rate,
+	}
+
+	if err := newRule.Validate(); err != nil {
+		return FeeRule{}, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return FeeRule{}, err
+	}
+	newRule.Id = id
+
+	return newRule, nil
+}
+
+func (rule *FeeRule) Validate() error {
+	switch rule.ServiceType {
+	case ServiceTypeSavings, ServiceTypeChequing, ServiceTypeLoan,
+		ServiceTypeLineOfCredit, ServiceTypeCertificateOfDeposit:
+	default:
+		return fmt.Errorf("fees can't be charged to services of type %q", rule.ServiceType)
+	}
+
+	switch rule.Kind {
+	case FeeKindTransfer, FeeKindMaintenance, FeeKindOverdraft, FeeKindInsufficientFunds,
+		FeeKindLate, FeeKindEarlyWithdrawal:
+	default:
+		return fmt.Errorf("unknown fee kind %q", rule.Kind)
+	}
+
+	if rule.Amount.IsNegative() || rule.Rate.IsNegative() {
+		return errors.New("fees can't be negative")
+	}
+
+	if rule.Amount.Exponent() < -2 {
+		return errors.New("fee amount can have at most 2 decimals")
+	}
+
+	if rule.Rate.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("fee rate can't be over 100%")
+	}
+
+	if rule.Kind != FeeKindTransfer && rule.Kind != FeeKindLate &&
+		rule.Kind != FeeKindEarlyWithdrawal && !rule.Rate.IsZero() {
+		return errors.New("only transfer, late and early withdrawal fees can be a percentage")
+	}
+
+	if rule.Amount.IsZero() && rule.Rate.IsZero() {
+		return errors.New("fee must charge something")
+	}
+
+	return nil
+}
+
+// Compute returns the fee charged on amount, rounded to cents.
+func (rule *FeeRule) Compute(amount decimal.Decimal) decimal.Decimal {
+	return rule.Amount.Add(amount.Mul(rule.Rate).Div(decimal.NewFromInt(100))).Round(2)
+}
+
+// NewFee creates the transaction that moves a fee from a service to the fee
+// income account of the bank, charges is the transaction that caused it.
+func NewFee(
+	kind, currency string,
+	amount decimal.Decimal,
+	src, dst uuid.UUID,
+	charges uuid.NullUUID,
+) (Transaction, error) {
+	fee, err := NewSettledTransaction(currency, amount, src, dst)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	fee.Fee = kind
+	fee.Charges = charges
+
+	return fee, nil
+}
+
+// Chargeable tells if fees apply to a transaction, fees and reversals are
+// never charged.
+func (transaction *Transaction) Chargeable() bool {
+	return transaction.Fee == "" && !transaction.Reverses.Valid
+}
+
+// MaintenancePeriod is the month the maintenance fee charged on day belongs
+// to, a service is charged at most once per period no matter how many times
+// the job runs in it.
+func MaintenancePeriod(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenancePeriodChargesOncePerMonth(t *testing.T) {
+	first := MaintenancePeriod(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC))
+	second := MaintenancePeriod(time.Date(2026, time.March, 31, 23, 0, 0, 0, time.UTC))
+	if !first.Equal(second) {
+		t.Errorf("runs on the same month are charged for %s and %s", first, second)
+	}
+
+	next := MaintenancePeriod(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	if next.Equal(first) {
+		t.Errorf("the next month is charged for the same period %s", next)
+	}
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Time a quoted exchange rate is guaranteed for
+	QuoteLifetime = 30 * time.Second
+)
+
+// ExchangeRate is the amount of Currency that one unit of Base buys.
+type ExchangeRate struct {
+	Base     string
+	Currency string
+	Rate     decimal.Decimal
+	Time     string
+}
+
+func (rate *ExchangeRate) Validate() error {
+	if len(rate.Base) != 3 || len(rate.Currency) != 3 {
+		return errors.New("exchange rate currencies must be ISO 4217 codes")
+	}
+	if !rate.Rate.IsPositive() {
+		return errors.New("exchange rate must be positive")
+	}
+	return nil
+}
+
+// Quote locks an exchange rate between two currencies until it expires.
+type Quote struct {
+	Id      uuid.UUID
+	Source  string
+	Target  string
+	Rate    decimal.Decimal
+	Expires time.Time
+}
+
+func NewQuote(source, target string, rate decimal.Decimal) (Quote, error) {
+	newQuote := Quote{
+		Source:  source,
+		Target:  target,
+		Rate:    rate,
+		Expires: time.Now().Add(QuoteLifetime),
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Quote{}, err
+	}
+	newQuote.Id = id
+
+	return newQuote, nil
+}
+
+// Convert returns the amount in the target currency, rounded to cents.
+func Convert(amount, rate decimal.Decimal) decimal.Decimal {
+	return amount.Mul(rate).Round(2)
+}
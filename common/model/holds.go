@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Hold state
+	HoldStateActive   = "ACT"
+	HoldStateCaptured = "CAP"
+	HoldStateVoided   = "VOI"
+	HoldStateExpired  = "EXP"
+)
+
+var (
+	ErrHoldNotActive      = errors.New("hold is not active")
+	ErrHoldCapturePending = errors.New("hold has a capture that did not execute yet")
+)
+
+// Hold reserves Amount of a service until it expires, it is either captured
+// into a transaction or voided to release the funds. Pending is set while a
+// capture waits to execute, the hold stays active until the capture succeeds
+// so a failed capture leaves the funds reserved.
+type Hold struct {
+	Id       uuid.UUID
+	Service  uuid.UUID
+	Amount   decimal.Decimal
+	Captured decimal.Decimal
+	State    string
+	Time     string
+	Expires  time.Time
+	Pending  bool
+}
+
+func NewHold(service uuid.UUID, amount decimal.Decimal, expires time.Time) (Hold, error) {
+	if !amount.IsPositive() || amount.Exponent() < -2 {
+		return Hold{}, errors.New("hold amount must be positive and can have at most 2 decimals")
+	}
+
+	if !expires.After(time.Now()) {
+		return Hold{}, errors.New("hold must expire in the future")
+	}
+
+	newHold := Hold{
+		Service:  service,
+		Amount:   amount,
+		Captured: decimal.Zero,
+		State:    HoldStateActive,
+		Time:     "NOW",
+		Expires:  expires,
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Hold{}, err
+	}
+	newHold.Id = id
+
+	return newHold, nil
+}
+
+// Active reports whether the hold still reserves funds, expired holds are
+// only marked as such by a background job so the expiry is checked here too.
+func (hold *Hold) Active() bool {
+	return hold.State == HoldStateActive && hold.Expires.After(time.Now())
+}
+
+// Capture turns the hold into a transaction for amount, while it is pending
+// only amount is reserved and the rest of the hold is released. The hold is
+// marked captured once the transaction succeeds.
+func (hold *Hold) Capture(
+	currency string, amount decimal.Decimal, destination uuid.UUID,
+) (Transaction, error) {
+	if !hold.Active() {
+		return Transaction{}, ErrHoldNotActive
+	}
+	if hold.Pending {
+		return Transaction{}, ErrHoldCapturePending
+	}
+
+	if !amount.IsPositive() || amount.GreaterThan(hold.Amount) {
+		return Transaction{}, errors.New("capture amount must be positive and within the hold")
+	}
+
+	transaction, err := NewTransaction(currency, amount, hold.Service, destination)
+	if err != nil {
+		return Transaction{}, err
+	}
+	transaction.Hold = uuid.NullUUID{UUID: hold.Id, Valid: true}
+	hold.Pending = true
+
+	return transaction, nil
+}
+
+func (hold *Hold) Void() error {
+	if !hold.Active() {
+		return ErrHoldNotActive
+	}
+	if hold.Pending {
+		return ErrHoldCapturePending
+	}
+
+	hold.State = HoldStateVoided
+	return nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestHoldCaptureFails(t *testing.T) {
+	hold, err := NewHold(uuid.New(), decimal.NewFromInt(100), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := hold.Capture("USD", decimal.NewFromInt(60), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.State != HoldStateActive || !hold.Captured.IsZero() {
+		t.Fatalf("hold is %s with %s captured before its capture executed",
+			hold.State, hold.Captured)
+	}
+
+	if _, err := hold.Capture("USD", decimal.NewFromInt(10), uuid.New()); !errors.Is(
+		err, ErrHoldCapturePending) {
+		t.Errorf("second capture returned %v, want %v", err, ErrHoldCapturePending)
+	}
+	if err := hold.Void(); !errors.Is(err, ErrHoldCapturePending) {
+		t.Errorf("void returned %v, want %v", err, ErrHoldCapturePending)
+	}
+
+	// the capture fails, once it is no longer pending the hold can be used
+	// again as if it was never captured
+	transaction.Fail(NewTransactionError(TransactionFailureInsufficientFunds,
+		"source %s has insufficient funds", transaction.Source))
+	hold.Pending = false
+
+	if !hold.Active() {
+		t.Fatalf("hold is %s after its capture failed", hold.State)
+	}
+	if _, err := hold.Capture("USD", decimal.NewFromInt(100), uuid.New()); err != nil {
+		t.Errorf("capture after a failed capture returned %v", err)
+	}
+}
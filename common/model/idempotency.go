@@ -0,0 +1,50 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+)
+
+// IdempotentRequest is a request made with an Idempotency-Key header, the
+// response is stored once the request finishes so retries can be replayed.
+type IdempotentRequest struct {
+	User        uuid.UUID
+	Key         string
+	Hash        string
+	Status      int
+	ContentType string
+	Response    []byte
+}
+
+// NewIdempotentRequest identifies a request by its method, path, query and
+// body. Anonymous requests have no user to scope their keys to, so they are
+// scoped to the request itself and only identical requests share a key.
+func NewIdempotentRequest(
+	user uuid.UUID, key, method, path, query string, body []byte,
+) IdempotentRequest {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(query))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	sum := hash.Sum(nil)
+
+	if user == uuid.Nil {
+		user = uuid.NewSHA1(uuid.Nil, sum)
+	}
+
+	return IdempotentRequest{
+		User: user,
+		Key:  key,
+		Hash: hex.EncodeToString(sum),
+	}
+}
+
+func (req *IdempotentRequest) Completed() bool {
+	return req.Status != 0
+}
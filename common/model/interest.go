@@ -0,0 +1,135 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Day count convention
+	DayCountActual365    = "ACT/365"
+	DayCountActual360    = "ACT/360"
+	DayCountActualActual = "ACT/ACT"
+	DayCount30360        = "30/360"
+)
+
+var ErrInterestNotAllowed = errors.New("service does not earn interest")
+
+// Interest is the rate a service earns and the interest it accrued but was
+// not paid yet. Rate is a yearly percentage, interest accrues every day on
+// the end of day balance and is paid at the end of every month.
+type Interest struct {
+	Service        uuid.UUID
+	Rate           decimal.Decimal
+	DayCount       string
+	Accrued        decimal.Decimal
+	AccruedThrough time.Time
+}
+
+// NewInterest starts accruing interest on the service today.
+func NewInterest(service uuid.UUID, rate decimal.Decimal, dayCount string) (Interest, error) {
+	if dayCount == "" {
+		dayCount = DayCountActual365
+	}
+
+	newInterest := Interest{
+		Service:        service,
+		Rate:           rate,
+		DayCount:       dayCount,
+		Accrued:        decimal.Zero,
+		AccruedThrough: Today().AddDate(0, 0, -1),
+	}
+
+	if err := newInterest.Validate(); err != nil {
+		return Interest{}, err
+	}
+
+	return newInterest, nil
+}
+
+func (interest *Interest) Validate() error {
+	if interest.Rate.IsNegative() || interest.Rate.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("interest rate must be between 0% and 100%")
+	}
+
+	switch interest.DayCount {
+	case DayCountActual365, DayCountActual360, DayCountActualActual, DayCount30360:
+	default:
+		return fmt.Errorf("unknown day count convention %q", interest.DayCount)
+	}
+
+	return nil
+}
+
+// Accrue adds the interest of the day after AccruedThrough on balance and
+// moves AccruedThrough forward. Negative balances don't accrue anything.
+func (interest *Interest) Accrue(balance decimal.Decimal) {
+	day := interest.AccruedThrough.AddDate(0, 0, 1)
+	if balance.IsPositive() {
+		days, basis := dayCount(interest.DayCount, day)
+		interest.Accrued = interest.Accrued.Add(balance.
+			Mul(interest.Rate).
+			Mul(decimal.NewFromInt(days)).
+			Div(decimal.NewFromInt(100 * basis)))
+	}
+	interest.AccruedThrough = day
+}
+
+// Due tells if the accrued interest has to be paid, that is at the end of
+// every month.
+func (interest *Interest) Due() bool {
+	return interest.AccruedThrough.AddDate(0, 0, 1).Day() == 1
+}
+
+// Capitalize returns the accrued interest in cents, the fraction of a cent
+// that is left keeps accruing.
+func (interest *Interest) Capitalize() decimal.Decimal {
+	paid := interest.Accrued.Truncate(2)
+	interest.Accrued = interest.Accrued.Sub(paid)
+	return paid
+}
+
+// NextCapitalization is the day the interest accrued so far will be paid.
+func (interest *Interest) NextCapitalization() time.Time {
+	day := interest.AccruedThrough.AddDate(0, 0, 1)
+	return time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// dayCount returns how many days a single day counts for and the number of
+// days in the year under the convention.
+func dayCount(convention string, day time.Time) (int64, int64) {
+	switch convention {
+	case DayCountActual360:
+		return 1, 360
+	case DayCountActualActual:
+		return 1, int64(time.Date(day.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay())
+	case DayCount30360:
+		// every month counts for 30 days, the 31st counts for nothing and the
+		// end of february makes up for the missing days
+		if day.Day() == 31 {
+			return 0, 360
+		}
+		if day.AddDate(0, 0, 1).Day() == 1 {
+			return int64(31 - day.Day()), 360
+		}
+		return 1, 360
+	default:
+		return 1, 365
+	}
+}
+
+// Today is the current day in UTC, days are always UTC so every replica
+// agrees on when a day ends.
+func Today() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// EarnsInterest tells if interest can be set on the service.
+func (srv *Service) EarnsInterest() bool {
+	return srv.Type == ServiceTypeSavings || srv.Type == ServiceTypeCertificateOfDeposit
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// accrue brings interest up to through the same way the accrual job does and
+// returns what was paid.
+func accrue(interest *Interest, through time.Time, balance decimal.Decimal) decimal.Decimal {
+	paid := decimal.Zero
+	for interest.AccruedThrough.Before(through) {
+		interest.Accrue(balance)
+		if interest.Due() {
+			paid = paid.Add(interest.Capitalize())
+		}
+	}
+	return paid
+}
+
+func TestInterestAccruesOncePerDay(t *testing.T) {
+	interest, err := NewInterest(uuid.New(), decimal.NewFromInt(5), DayCountActual365)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interest.AccruedThrough = time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+	through := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	balance := decimal.NewFromInt(100000)
+
+	paid := accrue(&interest, through, balance)
+	if !paid.IsPositive() {
+		t.Fatalf("first run paid %s", paid)
+	}
+
+	accrued := interest.Accrued
+	if paid := accrue(&interest, through, balance); !paid.IsZero() {
+		t.Errorf("second run through the same day paid %s", paid)
+	}
+	if !interest.Accrued.Equal(accrued) || !interest.AccruedThrough.Equal(through) {
+		t.Errorf("second run moved the accrual to %s through %s",
+			interest.Accrued, interest.AccruedThrough)
+	}
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Posting is a single movement on a service, negative amounts are debits and
+// positive amounts are credits. Balance is the balance of the service right
+// after the posting was applied.
+type Posting struct {
+	Id          uuid.UUID
+	Entry       uuid.UUID
+	Transaction uuid.UUID
+	Time        string
+	Service     uuid.UUID
+	Currency    string
+	Amount      decimal.Decimal
+	Balance     decimal.Decimal
+}
+
+// JournalEntry groups the postings produced by a single transaction, the
+// postings of an entry must add up to zero for every currency.
+type JournalEntry struct {
+	Id          uuid.UUID
+	Transaction uuid.UUID
+	Time        string
+	Postings    []Posting
+}
+
+func NewJournalEntry(transaction uuid.UUID) (JournalEntry, error) {
+	newEntry := JournalEntry{
+		Transaction: transaction,
+		Time:        "NOW",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	newEntry.Id = id
+
+	return newEntry, nil
+}
+
+func (entry *JournalEntry) post(
+	service uuid.UUID, currency string, amount decimal.Decimal,
+) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	entry.Postings = append(entry.Postings, Posting{
+		Id:          id,
+		Entry:       entry.Id,
+		Transaction: entry.Transaction,
+		Service:     service,
+		Currency:    currency,
+		Amount:      amount,
+	})
+	return nil
+}
+
+func (entry *JournalEntry) Debit(
+	service uuid.UUID, currency string, amount decimal.Decimal,
+) error {
+	return entry.post(service, currency, amount.Neg())
+}
+
+func (entry *JournalEntry) Credit(
+	service uuid.UUID, currency string, amount decimal.Decimal,
+) error {
+	return entry.post(service, currency, amount)
+}
+
+func (entry *JournalEntry) Validate() error {
+	if len(entry.Postings) < 2 {
+		return errors.New("journal entry needs at least two postings")
+	}
+
+	totals := make(map[string]decimal.Decimal)
+	for _, posting := range entry.Postings {
+		if !posting.Amount.IsPositive() && !posting.Amount.IsNegative() {
+			return fmt.Errorf("journal entry %s has an empty posting", entry.Id)
+		}
+		totals[posting.Currency] = totals[posting.Currency].Add(posting.Amount)
+	}
+
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("journal entry %s is unbalanced by %s %s",
+				entry.Id, total, currency)
+		}
+	}
+
+	return nil
+}
+
+// Reconciliation compares the stored balance of a service with the balance
+// derived from its postings.
+type Reconciliation struct {
+	Service  uuid.UUID
+	Balance  decimal.Decimal
+	Ledger   decimal.Decimal
+	Postings int64
+}
+
+func (rec *Reconciliation) Balanced() bool {
+	return rec.Balance.Equal(rec.Ledger)
+}
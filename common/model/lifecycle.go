@@ -0,0 +1,117 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidTransition   = errors.New("service can't move to that state")
+	ErrTransitionForbidden = errors.New("only tellers can move a service to that state")
+	ErrReasonRequired      = errors.New("freezing or closing a service needs a reason")
+	ErrServiceNotSettled   = errors.New("service has a balance or open obligations")
+)
+
+// serviceTransition is a state a service can move to from another one,
+// Teller is set on the transitions only tellers can make.
+type serviceTransition struct {
+	From   string
+	To     string
+	Teller bool
+}
+
+// Services are requested by their owners and activated by a teller, owners
+// can freeze their own services but only a teller can unfreeze them. Owners
+// can withdraw a request, services that were activated are closed by tellers
+// and closed services never change again.
+var serviceTransitions = []serviceTransition{
+	{ServiceStateRequested, ServiceStateActive, true},
+	{ServiceStateRequested, ServiceStateClosed, false},
+	{ServiceStateActive, ServiceStateFrozen, false},
+	{ServiceStateActive, ServiceStateClosed, true},
+	{ServiceStateFrozen, ServiceStateActive, true},
+	{ServiceStateFrozen, ServiceStateClosed, true},
+}
+
+// StateChange records a service moving from one state to another, User is
+// who made the change.
+type StateChange struct {
+	Id      uuid.UUID
+	Service uuid.UUID
+	User    uuid.NullUUID
+	From    string
+	To      string
+	Reason  string
+	Time    string
+}
+
+func NewStateChange(
+	service uuid.UUID,
+	user uuid.NullUUID,
+	state, reason string,
+) (StateChange, error) {
+	switch state {
+	case ServiceStateRequested, ServiceStateActive, ServiceStateFrozen, ServiceStateClosed:
+	default:
+		return StateChange{}, fmt.Errorf("unknown service state %q", state)
+	}
+
+	if len(reason) > 300 {
+		return StateChange{}, errors.New("reason can have at most 300 characters")
+	}
+
+	if reason == "" && (state == ServiceStateFrozen || state == ServiceStateClosed) {
+		return StateChange{}, ErrReasonRequired
+	}
+
+	newChange := StateChange{
+		Service: service,
+		User:    user,
+		To:      state,
+		Reason:  reason,
+		Time:    "NOW",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return StateChange{}, err
+	}
+	newChange.Id = id
+
+	return newChange, nil
+}
+
+// ChangeState moves the service to the state of change if the transition is
+// allowed, teller tells if the change is made by a teller. It sets the state
+// change started from.
+func (srv *Service) ChangeState(change *StateChange, teller bool) error {
+	for _, transition := range serviceTransitions {
+		if transition.From != srv.State || transition.To != change.To {
+			continue
+		}
+
+		if transition.Teller && !teller {
+			return ErrTransitionForbidden
+		}
+
+		change.From = srv.State
+		srv.State = change.To
+		return nil
+	}
+
+	return ErrInvalidTransition
+}
+
+// CheckSettled fails with ErrServiceNotSettled if the service still has money
+// or owes some, has active holds or unpaid loan installments. Loans and lines
+// of credit owe their negative balance.
+func (srv *Service) CheckSettled(unpaidInstallments int) error {
+	if !srv.InitBalance.Add(srv.Balance).IsZero() ||
+		!srv.Held.IsZero() ||
+		unpaidInstallments > 0 {
+		return ErrServiceNotSettled
+	}
+	return nil
+}
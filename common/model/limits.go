@@ -0,0 +1,97 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Limits caps the outgoing transactions of a service, a zero value means
+// there is no limit. Amounts are in the currency of the service.
+type Limits struct {
+	Service       uuid.UUID
+	MaxAmount     decimal.Decimal
+	DailyAmount   decimal.Decimal
+	MonthlyAmount decimal.Decimal
+	DailyCount    int
+}
+
+// LimitUsage is what a service already sent in the current day and month.
+type LimitUsage struct {
+	DailyAmount   decimal.Decimal
+	MonthlyAmount decimal.Decimal
+	DailyCount    int
+}
+
+// Allowance is what a service can still send, nil fields are unlimited.
+type Allowance struct {
+	MaxAmount     *decimal.Decimal
+	DailyAmount   *decimal.Decimal
+	MonthlyAmount *decimal.Decimal
+	DailyCount    *int
+}
+
+func (limits *Limits) Validate() error {
+	if limits.MaxAmount.IsNegative() ||
+		limits.DailyAmount.IsNegative() ||
+		limits.MonthlyAmount.IsNegative() ||
+		limits.DailyCount < 0 {
+		return errors.New("limits can't be negative")
+	}
+
+	return nil
+}
+
+// Check returns the first limit that sending amount on top of usage would
+// exceed.
+func (limits *Limits) Check(amount decimal.Decimal, usage LimitUsage) error {
+	if limits.MaxAmount.IsPositive() && amount.GreaterThan(limits.MaxAmount) {
+		return NewTransactionError(TransactionFailureLimitAmount,
+			"amount exceeds the limit of %s per transaction", limits.MaxAmount)
+	}
+
+	if limits.DailyAmount.IsPositive() &&
+		usage.DailyAmount.Add(amount).GreaterThan(limits.DailyAmount) {
+		return NewTransactionError(TransactionFailureLimitDailyAmount,
+			"amount exceeds the daily limit of %s", limits.DailyAmount)
+	}
+
+	if limits.MonthlyAmount.IsPositive() &&
+		usage.MonthlyAmount.Add(amount).GreaterThan(limits.MonthlyAmount) {
+		return NewTransactionError(TransactionFailureLimitMonthlyAmount,
+			"amount exceeds the monthly limit of %s", limits.MonthlyAmount)
+	}
+
+	if limits.DailyCount > 0 && usage.DailyCount+1 > limits.DailyCount {
+		return NewTransactionError(TransactionFailureLimitDailyCount,
+			"service already made %d transactions today", usage.DailyCount)
+	}
+
+	return nil
+}
+
+func (limits *Limits) Remaining(usage LimitUsage) Allowance {
+	var allowance Allowance
+
+	if limits.MaxAmount.IsPositive() {
+		allowance.MaxAmount = &limits.MaxAmount
+	}
+
+	if limits.DailyAmount.IsPositive() {
+		daily := decimal.Max(limits.DailyAmount.Sub(usage.DailyAmount), decimal.Zero)
+		allowance.DailyAmount = &daily
+	}
+
+	if limits.MonthlyAmount.IsPositive() {
+		monthly := decimal.Max(limits.MonthlyAmount.Sub(usage.MonthlyAmount), decimal.Zero)
+		allowance.MonthlyAmount = &monthly
+	}
+
+	if limits.DailyCount > 0 {
+		count := max(limits.DailyCount-usage.DailyCount, 0)
+		allowance.DailyCount = &count
+	}
+
+	return allowance
+}
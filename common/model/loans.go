@@ -0,0 +1,289 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Loan payment frequency
+	LoanFrequencyWeekly   = "WEK"
+	LoanFrequencyBiweekly = "BWK"
+	LoanFrequencyMonthly  = "MON"
+
+	// Installment state
+	InstallmentStatePending = "PEN"
+	InstallmentStatePaid    = "PAI"
+
+	// Maximum number of installments of a loan
+	LoanMaxTerm = 600
+)
+
+var (
+	ErrLoanOriginated   = errors.New("loan was already originated")
+	ErrLoanNotAllowed   = errors.New("service is not a loan")
+	ErrLoanDisbursement = errors.New("loan can't be disbursed to the service")
+)
+
+// Loan is a LOA service that owes Principal, the balance of the service is
+// the principal and late fees that are still owed. Rate is a yearly
+// percentage and Term the number of installments. The schedule is generated
+// when the service is activated, the principal is then sent to Disbursement.
+type Loan struct {
+	Service      uuid.UUID
+	Principal    decimal.Decimal
+	Rate         decimal.Decimal
+	Term         int
+	Frequency    string
+	Disbursement uuid.UUID
+	Originated   time.Time
+	Installments []Installment
+}
+
+// Installment is a single payment of a loan, Late is set once the late fee
+// was assessed and LateFee is what was charged for it.
+type Installment struct {
+	Number        int
+	Due           time.Time
+	Interest      decimal.Decimal
+	Principal     decimal.Decimal
+	LateFee       decimal.Decimal
+	PaidInterest  decimal.Decimal
+	PaidPrincipal decimal.Decimal
+	PaidLateFee   decimal.Decimal
+	Late          bool
+	State         string
+}
+
+// LoanAllocation is how a repayment was split.
+type LoanAllocation struct {
+	LateFee   decimal.Decimal
+	Interest  decimal.Decimal
+	Principal decimal.Decimal
+}
+
+// LoanQuote is what a loan owes on a day.
+type LoanQuote struct {
+	Outstanding decimal.Decimal
+	Arrears     decimal.Decimal
+	Payoff      decimal.Decimal
+}
+
+func NewLoan(
+	service uuid.UUID,
+	principal, rate decimal.Decimal,
+	term int,
+	frequency string,
+	disbursement uuid.UUID,
+) (Loan, error) {
+	newLoan := Loan{
+		Service:      service,
+		Principal:    principal,
+		Rate:         rate,
+		Term:         term,
+		Frequency:    frequency,
+		Disbursement: disbursement,
+	}
+
+	if err := newLoan.Validate(); err != nil {
+		return Loan{}, err
+	}
+
+	return newLoan, nil
+}
+
+func (loan *Loan) Validate() error {
+	if !loan.Principal.IsPositive() || loan.Principal.Exponent() < -2 {
+		return errors.New("loan principal must be positive with at most 2 decimals")
+	}
+
+	if loan.Rate.IsNegative() || loan.Rate.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("loan rate must be between 0% and 100%")
+	}
+
+	if loan.Term <= 0 || loan.Term > LoanMaxTerm {
+		return fmt.Errorf("loan term must be between 1 and %d installments", LoanMaxTerm)
+	}
+
+	switch loan.Frequency {
+	case LoanFrequencyWeekly, LoanFrequencyBiweekly, LoanFrequencyMonthly:
+	default:
+		return fmt.Errorf("unknown loan frequency %q", loan.Frequency)
+	}
+
+	if loan.Service == loan.Disbursement {
+		return errors.New("loan can't be disbursed to itself")
+	}
+
+	return nil
+}
+
+// periodsPerYear is the number of installments in a year, the yearly rate is
+// split evenly between them.
+func (loan *Loan) periodsPerYear() int64 {
+	switch loan.Frequency {
+	case LoanFrequencyWeekly:
+		return 52
+	case LoanFrequencyBiweekly:
+		return 26
+	default:
+		return 12
+	}
+}
+
+func (loan *Loan) dueDate(start time.Time, number int) time.Time {
+	switch loan.Frequency {
+	case LoanFrequencyWeekly:
+		return start.AddDate(0, 0, 7*number)
+	case LoanFrequencyBiweekly:
+		return start.AddDate(0, 0, 14*number)
+	default:
+		return addMonths(start, number)
+	}
+}
+
+// addMonths moves day forward by months, days past the end of a shorter month
+// fall on its last day.
+func addMonths(day time.Time, months int) time.Time {
+	month := time.Date(day.Year(), day.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := month.AddDate(0, 1, -1).Day()
+	return month.AddDate(0, 0, min(day.Day(), last)-1)
+}
+
+// Originate generates the amortization schedule starting on day, every
+// installment has the same payment except the last one which settles what
+// rounding left over.
+func (loan *Loan) Originate(day time.Time) error {
+	if !loan.Originated.IsZero() {
+		return ErrLoanOriginated
+	}
+
+	rate := loan.Rate.Div(decimal.NewFromInt(100 * loan.periodsPerYear()))
+	term := decimal.NewFromInt(int64(loan.Term))
+
+	payment := loan.Principal.Div(term).RoundUp(2)
+	if rate.IsPositive() {
+		growth := rate.Add(decimal.NewFromInt(1)).Pow(term)
+		payment = loan.Principal.Mul(rate).Mul(growth).
+			Div(growth.Sub(decimal.NewFromInt(1))).RoundUp(2)
+	}
+
+	remaining := loan.Principal
+	loan.Installments = make([]Installment, 0, loan.Term)
+	for number := 1; number <= loan.Term; number++ {
+		interest := remaining.Mul(rate).Round(2)
+		principal := payment.Sub(interest)
+		if number == loan.Term || principal.GreaterThan(remaining) {
+			principal = remaining
+		}
+		remaining = remaining.Sub(principal)
+
+		loan.Installments = append(loan.Installments, Installment{
+			Number:    number,
+			Due:       loan.dueDate(day, number),
+			Interest:  interest,
+			Principal: principal,
+			State:     InstallmentStatePending,
+		})
+	}
+
+	loan.Originated = day
+	return nil
+}
+
+// current is the index of the first installment that is not due before day,
+// its interest is owed in full when the loan is paid off.
+func (loan *Loan) current(day time.Time) int {
+	for i, installment := range loan.Installments {
+		if !installment.Due.Before(day) {
+			return i
+		}
+	}
+	return len(loan.Installments)
+}
+
+// Quote is what the loan owes on day, arrears are the unpaid amounts of
+// installments that were due before day. Paying off a loan waives the
+// interest of the installments after the current one.
+func (loan *Loan) Quote(day time.Time) LoanQuote {
+	quote := LoanQuote{
+		Outstanding: decimal.Zero,
+		Arrears:     decimal.Zero,
+		Payoff:      decimal.Zero,
+	}
+
+	current := loan.current(day)
+	for i, installment := range loan.Installments {
+		if installment.State == InstallmentStatePaid {
+			continue
+		}
+
+		principal := installment.Principal.Sub(installment.PaidPrincipal)
+		interest := installment.Interest.Sub(installment.PaidInterest)
+		lateFee := installment.LateFee.Sub(installment.PaidLateFee)
+
+		quote.Outstanding = quote.Outstanding.Add(principal)
+		quote.Payoff = quote.Payoff.Add(principal).Add(lateFee)
+		if i <= current {
+			quote.Payoff = quote.Payoff.Add(interest)
+		}
+		if i < current {
+			quote.Arrears = quote.Arrears.Add(principal).Add(interest).Add(lateFee)
+		}
+	}
+
+	return quote
+}
+
+// Repay splits amount between the installments on day. Installments up to
+// the current one are paid in order, late fee first, then interest and
+// principal. What is left prepays the principal of later installments, an
+// installment whose principal is prepaid doesn't owe its interest. Anything
+// over the payoff amount is not allocated.
+func (loan *Loan) Repay(amount decimal.Decimal, day time.Time) LoanAllocation {
+	allocation := LoanAllocation{
+		LateFee:   decimal.Zero,
+		Interest:  decimal.Zero,
+		Principal: decimal.Zero,
+	}
+
+	pay := func(owed, paid decimal.Decimal) decimal.Decimal {
+		part := decimal.Min(amount, owed.Sub(paid))
+		amount = amount.Sub(part)
+		return part
+	}
+
+	current := loan.current(day)
+	for i := range loan.Installments {
+		installment := &loan.Installments[i]
+		if installment.State == InstallmentStatePaid || !amount.IsPositive() {
+			continue
+		}
+
+		if i <= current {
+			part := pay(installment.LateFee, installment.PaidLateFee)
+			installment.PaidLateFee = installment.PaidLateFee.Add(part)
+			allocation.LateFee = allocation.LateFee.Add(part)
+
+			part = pay(installment.Interest, installment.PaidInterest)
+			installment.PaidInterest = installment.PaidInterest.Add(part)
+			allocation.Interest = allocation.Interest.Add(part)
+		}
+
+		part := pay(installment.Principal, installment.PaidPrincipal)
+		installment.PaidPrincipal = installment.PaidPrincipal.Add(part)
+		allocation.Principal = allocation.Principal.Add(part)
+
+		if installment.PaidPrincipal.Equal(installment.Principal) &&
+			installment.PaidLateFee.Equal(installment.LateFee) &&
+			(i > current || installment.PaidInterest.Equal(installment.Interest)) {
+			installment.State = InstallmentStatePaid
+		}
+	}
+
+	return allocation
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// What happens to a debit that goes over the overdraft limit
+	NSFPolicyReject = "REJ"
+	NSFPolicyPay    = "PAY"
+
+	// Overdraft state of a transaction, empty when it did not overdraw
+	TransactionOverdraftWithinLimit = "ODL"
+	TransactionOverdraftNSFPaid     = "NSF"
+)
+
+var ErrOverdraftNotAllowed = errors.New("service can't have an overdraft")
+
+// Overdraft lets a service go Limit below zero, the overdrawn balance pays
+// Interest. Debits over the limit fail or, when NSFPolicy is PAY, go through
+// and pay the NSF fee.
+type Overdraft struct {
+	Service   uuid.UUID
+	Limit     decimal.Decimal
+	NSFPolicy string
+	Interest  Interest
+}
+
+// NewOverdraft grants an overdraft that starts accruing interest today, rate
+// is a yearly percentage.
+func NewOverdraft(
+	service uuid.UUID,
+	limit, rate decimal.Decimal,
+	nsfPolicy string,
+) (Overdraft, error) {
+	if nsfPolicy == "" {
+		nsfPolicy = NSFPolicyReject
+	}
+
+	interest, err := NewInterest(service, rate, DayCountActual365)
+	if err != nil {
+		return Overdraft{}, err
+	}
+
+	newOverdraft := Overdraft{
+		Service:   service,
+		Limit:     limit,
+		NSFPolicy: nsfPolicy,
+		Interest:  interest,
+	}
+
+	if err := newOverdraft.Validate(); err != nil {
+		return Overdraft{}, err
+	}
+
+	return newOverdraft, nil
+}
+
+func (overdraft *Overdraft) Validate() error {
+	if overdraft.Limit.IsNegative() || overdraft.Limit.Exponent() < -2 {
+		return errors.New("overdraft limit can't be negative and can have at most 2 decimals")
+	}
+
+	switch overdraft.NSFPolicy {
+	case NSFPolicyReject, NSFPolicyPay:
+	default:
+		return fmt.Errorf("unknown NSF policy %q", overdraft.NSFPolicy)
+	}
+
+	return overdraft.Interest.Validate()
+}
+
+// Accrue adds the interest of the next day on the end of day balance, only
+// overdrawn balances pay interest.
+func (overdraft *Overdraft) Accrue(balance decimal.Decimal) {
+	overdraft.Interest.Accrue(balance.Neg())
+}
+
+// AllowsOverdraft tells if an overdraft can be granted on the service.
+func (srv *Service) AllowsOverdraft() bool {
+	return srv.Type == ServiceTypeSavings || srv.Type == ServiceTypeChequing
+}
+
+// Overdraw debits amount even when it goes over the overdraft limit, which
+// only services with an overdraft that pays NSF items can do.
+func (srv *Service) Overdraw(amount decimal.Decimal) error {
+	err := srv.checkDebit(amount)
+	var transactionErr *TransactionError
+	if errors.As(err, &transactionErr) &&
+		transactionErr.Code == TransactionFailureInsufficientFunds &&
+		srv.CheckPermissions(ServicePermissionOverdraft) && srv.NSFPolicy == NSFPolicyPay {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	srv.Balance = srv.Balance.Sub(amount)
+	return nil
+}
+
+// OverdraftState is how far the service is overdrawn, a transaction records
+// the state it left its source in.
+func (srv *Service) OverdraftState() string {
+	available := srv.Available()
+	switch {
+	case !available.IsNegative():
+		return ""
+	case available.Add(srv.OverdraftLimit).IsNegative():
+		return TransactionOverdraftNSFPaid
+	default:
+		return TransactionOverdraftWithinLimit
+	}
+}
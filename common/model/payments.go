@@ -0,0 +1,80 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Payment instruction status, named after ISO 20022
+	PaymentStatusPending  = "PDNG"
+	PaymentStatusAccepted = "ACCP"
+	PaymentStatusRejected = "RJCT"
+	// Only used for the whole import
+	PaymentStatusPartial = "PART"
+
+	// Payment rejection reason, named after ISO 20022
+	PaymentReasonInvalidFormat   = "FF01"
+	PaymentReasonInvalidAccount  = "AC01"
+	PaymentReasonClosedAccount   = "AC04"
+	PaymentReasonInvalidAmount   = "AM12"
+	PaymentReasonInvalidCurrency = "AM03"
+	PaymentReasonNotAllowed      = "AG01"
+
+	// Maximum number of instructions in an import
+	PaymentMaxInstructions = 5000
+)
+
+// PaymentInstruction is a single credit transfer of an import, instructions
+// that could not be parsed are rejected before they reach the database.
+type PaymentInstruction struct {
+	PaymentInfo   string
+	InstructionId string
+	EndToEndId    string
+	Currency      string
+	Amount        decimal.Decimal
+	Source        uuid.UUID
+	Destination   uuid.UUID
+
+	Status      string
+	Reason      string
+	Info        string
+	Transaction uuid.NullUUID
+}
+
+func (instruction *PaymentInstruction) Reject(reason, info string) {
+	instruction.Status = PaymentStatusRejected
+	instruction.Reason = reason
+	instruction.Info = info
+}
+
+func (instruction *PaymentInstruction) Accept(transaction uuid.UUID) {
+	instruction.Status = PaymentStatusAccepted
+	instruction.Transaction = uuid.NullUUID{UUID: transaction, Valid: true}
+}
+
+// PaymentImport is a file of credit transfers, each one is accepted or
+// rejected on its own.
+type PaymentImport struct {
+	MessageId    string
+	Instructions []PaymentInstruction
+}
+
+// Status sums up the status of every instruction.
+func (payments *PaymentImport) Status() string {
+	accepted := 0
+	for _, instruction := range payments.Instructions {
+		if instruction.Status == PaymentStatusAccepted {
+			accepted++
+		}
+	}
+
+	switch accepted {
+	case len(payments.Instructions):
+		return PaymentStatusAccepted
+	case 0:
+		return PaymentStatusRejected
+	default:
+		return PaymentStatusPartial
+	}
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Service permission names
+	ServicePermissionNameDebit     = "debit"
+	ServicePermissionNameCredit    = "credit"
+	ServicePermissionNameOverdraft = "overdraft"
+)
+
+var servicePermissionNames = []struct {
+	Mask int64
+	Name string
+}{
+	{ServicePermissionDebit, ServicePermissionNameDebit},
+	{ServicePermissionCredit, ServicePermissionNameCredit},
+	{ServicePermissionOverdraft, ServicePermissionNameOverdraft},
+}
+
+// PermissionChange records a permission of a service being granted or
+// revoked, User is the teller that made the change and is not set when the
+// bank made it on its own, like when a loan is originated.
+type PermissionChange struct {
+	Id         uuid.UUID
+	Service    uuid.UUID
+	User       uuid.NullUUID
+	Permission string
+	Granted    bool
+	Reason     string
+	Time       string
+}
+
+func NewPermissionChange(
+	service uuid.UUID,
+	user uuid.NullUUID,
+	permission string,
+	granted bool,
+	reason string,
+) (PermissionChange, error) {
+	if _, err := ParseServicePermission(permission); err != nil {
+		return PermissionChange{}, err
+	}
+
+	if reason == "" || len(reason) > 300 {
+		return PermissionChange{}, errors.New("reason must have between 1 and 300 characters")
+	}
+
+	newChange := PermissionChange{
+		Service:    service,
+		User:       user,
+		Permission: permission,
+		Granted:    granted,
+		Reason:     reason,
+		Time:       "NOW",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return PermissionChange{}, err
+	}
+	newChange.Id = id
+
+	return newChange, nil
+}
+
+// ParseServicePermission returns the bit of a permission given its name.
+func ParseServicePermission(name string) (int64, error) {
+	for _, permission := range servicePermissionNames {
+		if permission.Name == name {
+			return permission.Mask, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown service permission %q", name)
+}
+
+// PermissionNames lists the names of the permissions of the service.
+func (srv *Service) PermissionNames() []string {
+	names := make([]string, 0, len(servicePermissionNames))
+	for _, permission := range servicePermissionNames {
+		if srv.CheckPermissions(permission.Mask) {
+			names = append(names, permission.Name)
+		}
+	}
+	return names
+}
+
+// ApplyPermissionChange grants or revokes the permission of change, it tells
+// if the permissions of the service changed.
+func (srv *Service) ApplyPermissionChange(change PermissionChange) (bool, error) {
+	mask, err := ParseServicePermission(change.Permission)
+	if err != nil {
+		return false, err
+	}
+
+	permissions := srv.Permissions &^ mask
+	if change.Granted {
+		permissions |= mask
+	}
+
+	changed := permissions != srv.Permissions
+	srv.Permissions = permissions
+	return changed, nil
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Risk rule type
+	RiskRuleAmount   = "amount"
+	RiskRuleNewPayee = "new_payee"
+	RiskRuleVelocity = "velocity"
+	RiskRuleHour     = "hour"
+
+	// Risk action, allow stops the evaluation of the rules that follow
+	RiskActionAllow  = "allow"
+	RiskActionReject = "reject"
+	RiskActionReview = "review"
+
+	// Review decision
+	ReviewDecisionApproved = "APR"
+	ReviewDecisionDeclined = "DEC"
+)
+
+var ErrTransactionNotInReview = errors.New("transaction is not waiting for review")
+
+// RiskRule matches transactions of at least MinAmount, in Currency if it is
+// set, that also meet the condition of its type:
+//   - amount: nothing else
+//   - new_payee: the source never sent money to the destination before
+//   - velocity: the source sent more than MaxCount transactions or more than
+//     MaxAmount within Window, counting this one
+//   - hour: the transaction happens between FromHour and ToHour in Location
+type RiskRule struct {
+	Name      string
+	Type      string
+	Action    string
+	Currency  string
+	MinAmount decimal.Decimal
+	Window    time.Duration
+	MaxCount  int
+	MaxAmount decimal.Decimal
+	FromHour  int
+	ToHour    int
+	Location  *time.Location
+}
+
+func (rule *RiskRule) Validate() error {
+	if rule.Name == "" {
+		return errors.New("risk rule needs a name")
+	}
+
+	switch rule.Action {
+	case RiskActionAllow, RiskActionReject, RiskActionReview:
+	default:
+		return fmt.Errorf("risk rule %s has unknown action %q", rule.Name, rule.Action)
+	}
+
+	switch rule.Type {
+	case RiskRuleAmount, RiskRuleNewPayee:
+	case RiskRuleVelocity:
+		if rule.Window <= 0 {
+			return fmt.Errorf("velocity rule %s needs a window", rule.Name)
+		}
+		if rule.MaxCount <= 0 && !rule.MaxAmount.IsPositive() {
+			return fmt.Errorf("velocity rule %s needs a max count or amount", rule.Name)
+		}
+	case RiskRuleHour:
+		if rule.FromHour < 0 || rule.FromHour > 23 || rule.ToHour < 0 || rule.ToHour > 23 {
+			return fmt.Errorf("hour rule %s needs hours between 0 and 23", rule.Name)
+		}
+	default:
+		return fmt.Errorf("risk rule %s has unknown type %q", rule.Name, rule.Type)
+	}
+
+	return nil
+}
+
+// RiskFacts answers the questions about the history of a transaction that
+// rules need, they are only asked when a rule depends on them.
+type RiskFacts interface {
+	// NewPayee reports whether the source never sent money to the destination
+	NewPayee() (bool, error)
+	// Velocity returns the count and sum of the outgoing transactions of the
+	// source in the last window, not counting this one
+	Velocity(window time.Duration) (int, decimal.Decimal, error)
+}
+
+func (rule *RiskRule) matches(
+	transaction Transaction,
+	now time.Time,
+	facts RiskFacts,
+) (bool, error) {
+	if rule.Currency != "" && rule.Currency != transaction.Currency {
+		return false, nil
+	}
+
+	if transaction.Amount.LessThan(rule.MinAmount) {
+		return false, nil
+	}
+
+	switch rule.Type {
+	case RiskRuleNewPayee:
+		return facts.NewPayee()
+	case RiskRuleVelocity:
+		count, amount, err := facts.Velocity(rule.Window)
+		if err != nil {
+			return false, err
+		}
+		return (rule.MaxCount > 0 && count+1 > rule.MaxCount) ||
+			(rule.MaxAmount.IsPositive() &&
+				amount.Add(transaction.Amount).GreaterThan(rule.MaxAmount)), nil
+	case RiskRuleHour:
+		location := rule.Location
+		if location == nil {
+			location = time.UTC
+		}
+		hour := now.In(location).Hour()
+		if rule.FromHour <= rule.ToHour {
+			return hour >= rule.FromHour && hour <= rule.ToHour, nil
+		}
+		// the range wraps around midnight
+		return hour >= rule.FromHour || hour <= rule.ToHour, nil
+	}
+
+	return true, nil
+}
+
+// RiskDecision is the outcome of evaluating the rules on a transaction, Rule
+// is empty when no rule matched.
+type RiskDecision struct {
+	Action string
+	Rule   string
+}
+
+type RiskRules []RiskRule
+
+// Evaluate applies the rules in order, the first one that matches decides
+// what happens to the transaction. Transactions that match no rule are
+// allowed.
+func (rules RiskRules) Evaluate(
+	transaction Transaction,
+	now time.Time,
+	facts RiskFacts,
+) (RiskDecision, error) {
+	for _, rule := range rules {
+		matches, err := rule.matches(transaction, now, facts)
+		if err != nil {
+			return RiskDecision{}, err
+		}
+
+		if matches {
+			return RiskDecision{Action: rule.Action, Rule: rule.Name}, nil
+		}
+	}
+
+	return RiskDecision{Action: RiskActionAllow}, nil
+}
+
+// Review is a transaction parked by a risk rule, Decision stays empty until
+// a teller approves or declines it.
+type Review struct {
+	Transaction uuid.UUID
+	Rule        string
+	Time        string
+	Decision    string
+	Reviewer    uuid.NullUUID
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Schedule frequency
+	ScheduleFrequencyOnce    = "ONC"
+	ScheduleFrequencyDaily   = "DAY"
+	ScheduleFrequencyWeekly  = "WEK"
+	ScheduleFrequencyMonthly = "MON"
+
+	// Schedule state
+	ScheduleStateActive    = "ACT"
+	ScheduleStateCompleted = "CMP"
+	ScheduleStateCancelled = "CAN"
+
+	// Schedule execution outcome
+	ScheduleOutcomeQueued = "QUE"
+	ScheduleOutcomeFailed = "ERR"
+)
+
+// Schedule is a future dated transfer from Service to Destination, it runs
+// once or repeatedly until End or until it ran MaxRuns times. A zero End or
+// MaxRuns means there is no such limit.
+type Schedule struct {
+	Id          uuid.UUID
+	Service     uuid.UUID
+	Destination uuid.UUID
+	Currency    string
+	Amount      decimal.Decimal
+	Frequency   string
+	State       string
+	Start       time.Time
+	NextRun     time.Time
+	End         time.Time
+	MaxRuns     int
+	Runs        int
+}
+
+func NewSchedule(
+	service, destination uuid.UUID,
+	currency string,
+	amount decimal.Decimal,
+	frequency string,
+	start, end time.Time,
+	maxRuns int,
+) (Schedule, error) {
+	newSchedule := Schedule{
+		Service:     service,
+		Destination: destination,
+		Currency:    currency,
+		Amount:      amount,
+		Frequency:   frequency,
+		State:       ScheduleStateActive,
+		Start:       start,
+		NextRun:     start,
+		End:         end,
+		MaxRuns:     maxRuns,
+	}
+
+	if err := newSchedule.Validate(); err != nil {
+		return Schedule{}, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Schedule{}, err
+	}
+	newSchedule.Id = id
+
+	return newSchedule, nil
+}
+
+func (schedule *Schedule) Validate() error {
+	switch schedule.Frequency {
+	case ScheduleFrequencyOnce,
+		ScheduleFrequencyDaily,
+		ScheduleFrequencyWeekly,
+		ScheduleFrequencyMonthly:
+	default:
+		return errors.New("unknown schedule frequency")
+	}
+
+	if !schedule.Amount.IsPositive() || schedule.Amount.Exponent() < -2 {
+		return errors.New("schedule amount must be positive and can have at most 2 decimals")
+	}
+	if schedule.Service == schedule.Destination {
+		return errors.New("schedule source and destination are the same")
+	}
+	if schedule.MaxRuns < 0 {
+		return errors.New("schedule count must not be negative")
+	}
+	if !schedule.End.IsZero() && schedule.End.Before(schedule.Start) {
+		return errors.New("schedule ends before it starts")
+	}
+	return nil
+}
+
+// Advance records a run and moves NextRun forward, the schedule is completed
+// once there are no runs left. Runs are always computed from Start so monthly
+// schedules don't drift after short months.
+func (schedule *Schedule) Advance() {
+	schedule.Runs++
+
+	switch schedule.Frequency {
+	case ScheduleFrequencyDaily:
+		schedule.NextRun = schedule.Start.AddDate(0, 0, schedule.Runs)
+	case ScheduleFrequencyWeekly:
+		schedule.NextRun = schedule.Start.AddDate(0, 0, 7*schedule.Runs)
+	case ScheduleFrequencyMonthly:
+		schedule.NextRun = AddMonths(schedule.Start, schedule.Runs)
+	default:
+		schedule.State = ScheduleStateCompleted
+	}
+
+	if schedule.Exhausted() {
+		schedule.State = ScheduleStateCompleted
+	}
+}
+
+// Exhausted tells if the schedule has no runs left, because it already ran
+// MaxRuns times or because its next run falls after End.
+func (schedule *Schedule) Exhausted() bool {
+	return (schedule.MaxRuns > 0 && schedule.Runs >= schedule.MaxRuns) ||
+		(!schedule.End.IsZero() && schedule.NextRun.After(schedule.End))
+}
+
+// Missed tells if the run after NextRun is also due at now, which happens
+// when the scheduler was down. Only the latest due run is executed, the ones
+// before it are skipped and still count as runs so the schedule keeps its
+// dates and never pays more than MaxRuns times.
+func (schedule *Schedule) Missed(now time.Time) bool {
+	following := *schedule
+	following.Advance()
+	return following.State == ScheduleStateActive && !following.NextRun.After(now)
+}
+
+func (schedule *Schedule) Cancel() error {
+	if schedule.State != ScheduleStateActive {
+		return errors.New("schedule is not active")
+	}
+	schedule.State = ScheduleStateCancelled
+	return nil
+}
+
+// AddMonths adds months to t, clamping the day to the end of the month
+// instead of overflowing into the next one.
+func AddMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1,
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// ScheduleExecution is a single run of a schedule, TransactionState and
+// FailureCode come from the transaction it created.
+type ScheduleExecution struct {
+	Id               uuid.UUID
+	Schedule         uuid.UUID
+	Time             string
+	Transaction      uuid.NullUUID
+	Outcome          string
+	Reason           string
+	TransactionState string
+	FailureCode      string
+}
+
+func NewScheduleExecution(schedule uuid.UUID) (ScheduleExecution, error) {
+	newExecution := ScheduleExecution{
+		Schedule: schedule,
+		Time:     "NOW",
+		Outcome:  ScheduleOutcomeQueued,
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return ScheduleExecution{}, err
+	}
+	newExecution.Id = id
+
+	return newExecution, nil
+}
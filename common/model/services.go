@@ -1,8 +1,6 @@
 package model
 
 import (
-	"fmt"
-
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -14,6 +12,7 @@ const (
 	ServiceTypeLoan                 = "LOA"
 	ServiceTypeLineOfCredit         = "LOC"
 	ServiceTypeCertificateOfDeposit = "COD"
+	ServiceTypeBank                 = "BNK"
 
 	// Service state
 	ServiceStateRequested = "REQ"
@@ -35,6 +34,18 @@ type Service struct {
 	Currency    string
 	InitBalance decimal.Decimal
 	Balance     decimal.Decimal
+
+	// Held is the amount reserved by outstanding holds, it is not part of the
+	// ledger balance but it can't be spent.
+	Held decimal.Decimal
+
+	// CreditLimit is how much a line of credit can owe.
+	CreditLimit decimal.Decimal
+
+	// OverdraftLimit is how far below zero a service with the overdraft
+	// permission can go, NSFPolicy is what happens to debits that go further.
+	OverdraftLimit decimal.Decimal
+	NSFPolicy      string
 }
 
 func NewService(mType, currency string, initBalance decimal.Decimal) (Service, error) {
@@ -65,22 +76,50 @@ func (srv *Service) CheckPermissions(mask int64) bool {
 	}
 }
 
-func (srv *Service) Debit(amount decimal.Decimal) error {
+// Available is the amount that can be spent without going into overdraft,
+// lines of credit can spend up to their credit limit.
+func (srv *Service) Available() decimal.Decimal {
+	return srv.Balance.Add(srv.InitBalance).Sub(srv.Held).Add(srv.CreditLimit)
+}
+
+func (srv *Service) checkDebit(amount decimal.Decimal) error {
 	if !srv.CheckPermissions(ServicePermissionDebit) {
-		return fmt.Errorf("service %s does not have debit permission", srv.Id)
+		return NewTransactionError(TransactionFailureDebitNotAllowed,
+			"service %s does not have debit permission", srv.Id)
+	}
+	// lines of credit never go over their limit, not even with overdraft
+	remaining := srv.Available().Sub(amount)
+	if srv.Type != ServiceTypeLineOfCredit && srv.CheckPermissions(ServicePermissionOverdraft) {
+		remaining = remaining.Add(srv.OverdraftLimit)
+	}
+	if remaining.IsNegative() {
+		return NewTransactionError(TransactionFailureInsufficientFunds,
+			"service %s has insufficient funds", srv.Id)
 	}
-	newBalance := srv.Balance.Sub(amount)
-	if newBalance.Add(srv.InitBalance).IsNegative() &&
-		!srv.CheckPermissions(ServicePermissionOverdraft) {
-		return fmt.Errorf("service %s does not have overdraft permission", srv.Id)
+	return nil
+}
+
+func (srv *Service) Debit(amount decimal.Decimal) error {
+	if err := srv.checkDebit(amount); err != nil {
+		return err
 	}
-	srv.Balance = newBalance
+	srv.Balance = srv.Balance.Sub(amount)
+	return nil
+}
+
+// Reserve places amount on hold, it follows the same rules as a debit.
+func (srv *Service) Reserve(amount decimal.Decimal) error {
+	if err := srv.checkDebit(amount); err != nil {
+		return err
+	}
+	srv.Held = srv.Held.Add(amount)
 	return nil
 }
 
 func (srv *Service) Credit(amount decimal.Decimal) error {
-	if !srv.CheckPermissions(ServicePermissionDebit) {
-		return fmt.Errorf("service %s does not have credit permission", srv.Id)
+	if !srv.CheckPermissions(ServicePermissionCredit) {
+		return NewTransactionError(TransactionFailureCreditNotAllowed,
+			"service %s does not have credit permission", srv.Id)
 	}
 	srv.Balance = srv.Balance.Add(amount)
 	return nil
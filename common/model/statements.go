@@ -0,0 +1,97 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// Statement format
+	StatementFormatCSV     = "csv"
+	StatementFormatOFX     = "ofx"
+	StatementFormatQIF     = "qif"
+	StatementFormatCamt053 = "camt053"
+
+	// Longest period a single statement can cover
+	StatementMaxPeriod = 366 * 24 * time.Hour
+)
+
+// StatementLine is a posting on the service with the other side of the
+// transaction that produced it, Balance includes the initial balance.
+type StatementLine struct {
+	Id           uuid.UUID
+	Transaction  uuid.UUID
+	Time         time.Time
+	Amount       decimal.Decimal
+	Balance      decimal.Decimal
+	Counterparty uuid.UUID
+}
+
+// Description is a short human readable text for the line.
+func (line *StatementLine) Description() string {
+	if line.Amount.IsNegative() {
+		return "Transfer to " + line.Counterparty.String()
+	}
+	return "Transfer from " + line.Counterparty.String()
+}
+
+// Statement lists what happened on a service between From, inclusive, and
+// To, exclusive.
+type Statement struct {
+	Service     uuid.UUID
+	Type        string
+	Currency    string
+	From        time.Time
+	To          time.Time
+	InitBalance decimal.Decimal
+	Opening     decimal.Decimal
+	Closing     decimal.Decimal
+	Lines       []StatementLine
+	Time        time.Time
+}
+
+// NewStatement starts an empty statement, posted is the balance of the last
+// posting before from. The initial balance of the service is never posted so
+// it is added to the opening balance and to the balance of every line.
+func NewStatement(
+	service uuid.UUID,
+	serviceType, currency string,
+	from, to time.Time,
+	initBalance, posted decimal.Decimal,
+) Statement {
+	opening := posted.Add(initBalance)
+	return Statement{
+		Service:     service,
+		Type:        serviceType,
+		Currency:    currency,
+		From:        from,
+		To:          to,
+		InitBalance: initBalance,
+		Opening:     opening,
+		Closing:     opening,
+		Time:        time.Now(),
+	}
+}
+
+// AddLine appends a posting to the statement, the balance of the line is the
+// one of the posting and the last line closes the statement.
+func (statement *Statement) AddLine(line StatementLine) {
+	line.Balance = line.Balance.Add(statement.InitBalance)
+	statement.Lines = append(statement.Lines, line)
+	statement.Closing = line.Balance
+}
+
+func ValidateStatementPeriod(from, to time.Time) error {
+	if !to.After(from) {
+		return errors.New("statement period ends before it starts")
+	}
+
+	if to.Sub(from) > StatementMaxPeriod {
+		return errors.New("statement period is longer than a year")
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -10,8 +14,55 @@ const (
 	TransactionStateProcessing = "PRC"
 	TransactionStateError      = "ERR"
 	TransactionStateSuccess    = "SUC"
+	TransactionStateReview     = "REV"
+
+	// Transaction failure codes
+	TransactionFailureInvalid             = "INVALID_TRANSACTION"
+	TransactionFailureServiceNotFound     = "SERVICE_NOT_FOUND"
+	TransactionFailureSourceInactive      = "SOURCE_INACTIVE"
+	TransactionFailureDestinationInactive = "DESTINATION_INACTIVE"
+	TransactionFailureDebitNotAllowed     = "DEBIT_NOT_ALLOWED"
+	TransactionFailureCreditNotAllowed    = "CREDIT_NOT_ALLOWED"
+	TransactionFailureInsufficientFunds   = "INSUFFICIENT_FUNDS"
+	TransactionFailureCurrencyMismatch    = "CURRENCY_MISMATCH"
+	TransactionFailureRateUnavailable     = "RATE_UNAVAILABLE"
+	TransactionFailureQuoteInvalid        = "QUOTE_INVALID"
+	TransactionFailureBatchAborted        = "BATCH_ABORTED"
+	TransactionFailureLimitAmount         = "LIMIT_AMOUNT"
+	TransactionFailureLimitDailyAmount    = "LIMIT_DAILY_AMOUNT"
+	TransactionFailureLimitMonthlyAmount  = "LIMIT_MONTHLY_AMOUNT"
+	TransactionFailureLimitDailyCount     = "LIMIT_DAILY_COUNT"
+	TransactionFailureRiskRejected        = "RISK_REJECTED"
+	TransactionFailureReviewDeclined      = "REVIEW_DECLINED"
+	TransactionFailureDepositNotMatured   = "DEPOSIT_NOT_MATURED"
+	TransactionFailureInternal            = "INTERNAL_ERROR"
+)
+
+var (
+	ErrTransactionNotReversible = errors.New("transaction can not be reversed")
+	ErrReversalExceedsOriginal  = errors.New("reversal exceeds the amount left to reverse")
+	ErrTransactionAmount        = errors.New(
+		"transaction amount must be positive and can have at most 2 decimals")
 )
 
+// TransactionError is the reason a transaction could not be executed, Code is
+// meant for machines and Message for the customer.
+type TransactionError struct {
+	Code    string
+	Message string
+}
+
+func NewTransactionError(code, format string, a ...any) *TransactionError {
+	return &TransactionError{
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func (err *TransactionError) Error() string {
+	return err.Message
+}
+
 type Transaction struct {
 	Id          uuid.UUID
 	State       string
@@ -20,14 +71,55 @@ type Transaction struct {
 	Amount      decimal.Decimal
 	Source      uuid.UUID
 	Destination uuid.UUID
+
+	// Quote is the locked exchange rate requested by the client, Rate and
+	// SettledAmount are the rate applied and the amount credited to the
+	// destination in its own currency.
+	Quote         uuid.NullUUID
+	Rate          decimal.Decimal
+	SettledAmount decimal.Decimal
+
+	// Reverses links a compensating transaction to the one it undoes, the
+	// original lists its compensating transactions in Reversals.
+	Reverses  uuid.NullUUID
+	Reversals []uuid.UUID
+
+	// Hold is the authorization hold this transaction captures.
+	Hold uuid.NullUUID
+
+	// Batch is set on the legs of a batch, they are executed together.
+	Batch uuid.NullUUID
+
+	// Fee is the kind of fee a transaction charges, Charges links it to the
+	// transaction it was charged for and the original lists them in Fees.
+	Fee     string
+	Charges uuid.NullUUID
+	Fees    []uuid.UUID
+
+	// AcceptPenalty lets the transaction debit a deposit before it matures,
+	// the early withdrawal fee is charged on top of the amount.
+	AcceptPenalty bool
+
+	// Overdraft is how far the transaction overdrew its source, empty when it
+	// did not.
+	Overdraft string
+
+	FailureCode   string
+	FailureReason string
 }
 
+// NewTransaction creates a transaction that waits in the queue, every way
+// into the queue goes through here so the amount is checked here.
 func NewTransaction(
 	currency string,
 	amount decimal.Decimal,
 	src,
 	dst uuid.UUID,
 ) (Transaction, error) {
+	if !amount.IsPositive() || amount.Exponent() < -2 {
+		return Transaction{}, ErrTransactionAmount
+	}
+
 	newTransaction := Transaction{
 		State:       TransactionStateProcessing,
 		Time:        "NOW",
@@ -45,3 +137,107 @@ func NewTransaction(
 
 	return newTransaction, nil
 }
+
+// NewSettledTransaction creates a transaction between services of the same
+// currency that the bank applies right away instead of queueing it.
+func NewSettledTransaction(
+	currency string,
+	amount decimal.Decimal,
+	src,
+	dst uuid.UUID,
+) (Transaction, error) {
+	transaction, err := NewTransaction(currency, amount, src, dst)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	transaction.State = TransactionStateSuccess
+	transaction.Rate = decimal.NewFromInt(1)
+	transaction.SettledAmount = amount
+
+	return transaction, nil
+}
+
+func (transaction *Transaction) Fail(err *TransactionError) {
+	transaction.State = TransactionStateError
+	transaction.FailureCode = err.Code
+	transaction.FailureReason = err.Message
+}
+
+// NewReversal creates a transaction that sends amount back from the
+// destination of original to its source, reversed is how much of the original
+// was already reversed. Amounts are in currency, the currency of the
+// destination.
+func NewReversal(
+	original Transaction,
+	currency string,
+	amount,
+	reversed decimal.Decimal,
+) (Transaction, error) {
+	if original.State != TransactionStateSuccess || original.Reverses.Valid {
+		return Transaction{}, ErrTransactionNotReversible
+	}
+
+	remaining := original.SettledAmount.Sub(reversed)
+	if !remaining.IsPositive() || amount.GreaterThan(remaining) {
+		return Transaction{}, ErrReversalExceedsOriginal
+	}
+
+	if !amount.IsPositive() {
+		return Transaction{}, errors.New("reversal amount must be positive")
+	}
+
+	reversal, err := NewTransaction(currency, amount, original.Destination, original.Source)
+	if err != nil {
+		return Transaction{}, err
+	}
+	reversal.Reverses = uuid.NullUUID{UUID: original.Id, Valid: true}
+
+	return reversal, nil
+}
+
+const (
+	// Direction of the transactions of a service in a listing
+	TransactionDirectionIn   = "in"
+	TransactionDirectionOut  = "out"
+	TransactionDirectionBoth = "both"
+)
+
+// TransactionFilter narrows down transaction listings, zero values don't
+// filter. Direction and Counterparty are relative to the service being
+// listed, without one Counterparty matches either side.
+type TransactionFilter struct {
+	Direction    string
+	State        string
+	From         time.Time
+	To           time.Time
+	MinAmount    decimal.NullDecimal
+	MaxAmount    decimal.NullDecimal
+	Counterparty uuid.NullUUID
+}
+
+func (filter *TransactionFilter) Validate() error {
+	switch filter.Direction {
+	case "", TransactionDirectionIn, TransactionDirectionOut, TransactionDirectionBoth:
+	default:
+		return fmt.Errorf("unknown direction %q", filter.Direction)
+	}
+
+	switch filter.State {
+	case "", TransactionStateProcessing, TransactionStateError,
+		TransactionStateSuccess, TransactionStateReview:
+	default:
+		return fmt.Errorf("unknown state %q", filter.State)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return errors.New("date range ends before it starts")
+	}
+
+	if filter.MinAmount.Valid && filter.MaxAmount.Valid &&
+		filter.MaxAmount.Decimal.LessThan(filter.MinAmount.Decimal) {
+		return errors.New("amount range ends before it starts")
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Webhook event
+	WebhookEventTransactionSucceeded = "transaction.succeeded"
+	WebhookEventTransactionFailed    = "transaction.failed"
+	WebhookEventServiceStateChanged  = "service.state_changed"
+	WebhookEventUserUpdated          = "user.updated"
+
+	// Delivery state
+	DeliveryStatePending   = "PEN"
+	DeliveryStateDelivered = "DLV"
+	DeliveryStateFailed    = "FAI"
+
+	// Number of attempts before a delivery is given up
+	DeliveryMaxAttempts = 8
+	// Delay before the first retry, it doubles on every attempt
+	DeliveryRetryDelay = 30 * time.Second
+	// Time a claimed delivery is hidden from other dispatchers while it is
+	// being sent, it must be longer than the timeout of the http client
+	DeliveryClaimTimeout = time.Minute
+)
+
+var webhookEvents = []string{
+	WebhookEventTransactionSucceeded,
+	WebhookEventTransactionFailed,
+	WebhookEventServiceStateChanged,
+	WebhookEventUserUpdated,
+}
+
+var ErrWebhookAddress = errors.New("webhook url must point to a public address")
+
+// Webhook receives the events of its user, webhooks of tellers receive the
+// events of everyone.
+type Webhook struct {
+	Id     uuid.UUID
+	User   uuid.UUID
+	Url    string
+	Secret string
+	Events []string
+	Time   string
+}
+
+// NewWebhook registers endpoint for the events of user, only tellers can use
+// plain http. Hosts given as addresses must be public, names are checked
+// again once they are resolved.
+func NewWebhook(
+	user uuid.UUID,
+	teller bool,
+	endpoint string,
+	events []string,
+) (Webhook, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if parsed.Scheme != "https" && (parsed.Scheme != "http" || !teller) {
+		return Webhook{}, errors.New("webhook url must be https")
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if host == "" {
+		return Webhook{}, errors.New("webhook url needs a host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return Webhook{}, ErrWebhookAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if err := CheckWebhookAddress(addr); err != nil {
+			return Webhook{}, err
+		}
+	}
+
+	if len(events) == 0 {
+		return Webhook{}, errors.New("webhook needs at least one event")
+	}
+	for _, event := range events {
+		if !isWebhookEvent(event) {
+			return Webhook{}, fmt.Errorf("unknown webhook event %q", event)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Webhook{}, err
+	}
+
+	newWebhook := Webhook{
+		User:   user,
+		Url:    endpoint,
+		Secret: hex.EncodeToString(secret),
+		Events: events,
+		Time:   "NOW",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Webhook{}, err
+	}
+	newWebhook.Id = id
+
+	return newWebhook, nil
+}
+
+// CheckWebhookAddress fails for addresses that are not reachable on the
+// internet: loopback, link-local, private and unspecified ones.
+func CheckWebhookAddress(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+func isWebhookEvent(event string) bool {
+	for _, known := range webhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and body joined
+// by a dot, receivers check it with the secret they got on registration.
+func (webhook *Webhook) Sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Delivery is a single event sent to a webhook, Payload is the exact body
+// that is sent on every attempt.
+type Delivery struct {
+	Id          uuid.UUID
+	Webhook     uuid.UUID
+	Event       string
+	Payload     []byte
+	State       string
+	Attempts    int
+	NextAttempt time.Time
+	LastStatus  int
+	LastError   string
+	Time        string
+}
+
+// NewDelivery builds the payload of an event for a webhook, data is
+// serialized as the data field of the payload.
+func NewDelivery(webhook uuid.UUID, event string, data map[string]string) (Delivery, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	payload, err := json.Marshal(struct {
+		Id    string            `json:"id"`
+		Event string            `json:"event"`
+		Time  string            `json:"time"`
+		Data  map[string]string `json:"data"`
+	}{
+		Id:    id.String(),
+		Event: event,
+		Time:  time.Now().UTC().Format(time.RFC3339),
+		Data:  data,
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	return Delivery{
+		Id:          id,
+		Webhook:     webhook,
+		Event:       event,
+		Payload:     payload,
+		State:       DeliveryStatePending,
+		NextAttempt: time.Now(),
+		Time:        "NOW",
+	}, nil
+}
+
+// Record updates the delivery with the outcome of an attempt, failed attempts
+// are retried with exponential backoff until DeliveryMaxAttempts.
+func (delivery *Delivery) Record(status int, err error) {
+	delivery.Attempts++
+	delivery.LastStatus = status
+	delivery.LastError = ""
+
+	if err == nil && status >= 200 && status < 300 {
+		delivery.State = DeliveryStateDelivered
+		return
+	}
+
+	if err != nil {
+		delivery.LastError = err.Error()
+	} else {
+		delivery.LastError = fmt.Sprintf("endpoint responded with status %d", status)
+	}
+
+	if delivery.Attempts >= DeliveryMaxAttempts {
+		delivery.State = DeliveryStateFailed
+		return
+	}
+
+	delivery.NextAttempt = time.Now().Add(DeliveryRetryDelay << (delivery.Attempts - 1))
+}
+
+// Redeliver puts the delivery back in the queue with a fresh set of attempts.
+func (delivery *Delivery) Redeliver() {
+	delivery.State = DeliveryStatePending
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Now()
+}
+
+// TransactionEventData is the data of the events of a transaction.
+func TransactionEventData(transaction Transaction) map[string]string {
+	return map[string]string{
+		"id":             transaction.Id.String(),
+		"state":          transaction.State,
+		"currency":       transaction.Currency,
+		"amount":         transaction.Amount.String(),
+		"source":         transaction.Source.String(),
+		"destination":    transaction.Destination.String(),
+		"fee":            transaction.Fee,
+		"failure_code":   transaction.FailureCode,
+		"failure_reason": transaction.FailureReason,
+	}
+}
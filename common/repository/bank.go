@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// findBankAccount returns the service the bank uses for the given purpose and
+// currency, these are created along with the database.
+func findBankAccount(
+	ctx context.Context, q querier, purpose, currency string,
+) (uuid.UUID, error) {
+	row := q.QueryRowContext(ctx,
+		`select service_id from bank_accounts where (purpose, currency) = ($1, $2)`,
+		purpose, currency)
+
+	var id uuid.UUID
+	if err := row.Scan(&id); err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return id, nil
+}
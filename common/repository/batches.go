@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type BatchesRepository struct {
+	db    *sql.DB
+	rules model.RiskRules
+}
+
+func NewBchRepository(db *sql.DB, rules model.RiskRules) BatchesRepository {
+	return BatchesRepository{db, rules}
+}
+
+// CreateBatch stores the batch with all its legs and queues it as a whole.
+func (repo *BatchesRepository) CreateBatch(ctx context.Context, batch model.Batch) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `insert
+        into batches(id, state, time)
+        values ($1, $2, $3)
+        returning time`,
+		batch.Id,
+		batch.State,
+		batch.Time)
+	if err := row.Scan(&batch.Time); err != nil {
+		return err
+	}
+
+	for i := range batch.Legs {
+		if err := insertTransaction(ctx, tx, &batch.Legs[i]); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`insert into batch_queue(batch_id) values ($1)`, batch.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func findBatch(ctx context.Context, q querier, id uuid.UUID) (model.Batch, error) {
+	row := q.QueryRowContext(ctx,
+		`select id, state, time, failure_code, failure_reason from batches where id = $1`, id)
+
+	var batch model.Batch
+	if err := row.Scan(
+		&batch.Id,
+		&batch.State,
+		&batch.Time,
+		&batch.FailureCode,
+		&batch.FailureReason); err != nil {
+		return model.Batch{}, err
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`select `+transactionColumns+` from transactions where batch = $1 order by id`, id)
+	if err != nil {
+		return model.Batch{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var leg model.Transaction
+		if err := scanTransaction(rows, &leg); err != nil {
+			return model.Batch{}, err
+		}
+		batch.Legs = append(batch.Legs, leg)
+	}
+
+	return batch, rows.Err()
+}
+
+func (repo *BatchesRepository) FindBatch(ctx context.Context, id uuid.UUID) (model.Batch, error) {
+	return findBatch(ctx, repo.db, id)
+}
+
+// RequeueBatches puts every batch still in processing state back in the
+// queue, this recovers batches that were left behind by a crash.
+func (repo *BatchesRepository) RequeueBatches(ctx context.Context) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, `insert
+        into batch_queue(batch_id)
+        select id from batches where state = $1
+        on conflict do nothing`,
+		model.TransactionStateProcessing)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ProcessBatch claims the oldest queued batch and executes every leg in a
+// single database transaction, if any leg fails none of them are applied. It
+// returns ErrQueueEmpty when there is nothing to claim.
+func (repo *BatchesRepository) ProcessBatch(ctx context.Context) (model.Batch, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Batch{}, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select batch_id from batch_queue
+        where available <= now()
+        order by enqueued
+        limit 1
+        for update skip locked`)
+
+	var batchId uuid.UUID
+	if err := row.Scan(&batchId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Batch{}, ErrQueueEmpty
+		}
+		return model.Batch{}, err
+	}
+
+	batch, err := findBatch(ctx, tx, batchId)
+	if err != nil {
+		return model.Batch{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "savepoint execute"); err != nil {
+		return batch, err
+	}
+
+	// every leg locks its services again, taking all of them up front keeps
+	// the locking order deterministic across the whole batch
+	var execErr error
+	failed := -1
+	if _, execErr = lockServices(ctx, tx, batch.Services()...); execErr == nil {
+		for i, leg := range batch.Legs {
+			if execErr = assessBatchLeg(ctx, tx, repo.rules, leg); execErr != nil {
+				failed = i
+				break
+			}
+			if execErr = executeTransaction(ctx, tx, leg); execErr != nil {
+				failed = i
+				break
+			}
+		}
+	}
+
+	if execErr != nil {
+		if _, err := tx.ExecContext(ctx, "rollback to savepoint execute"); err != nil {
+			return batch, err
+		}
+
+		if isTransient(execErr) {
+			retry, err := retryQueueEntry(ctx, tx, "batch_queue", "batch_id", batch.Id)
+			if err != nil {
+				return batch, err
+			}
+
+			if retry {
+				if err := tx.Commit(); err != nil {
+					return batch, err
+				}
+				return batch, fmt.Errorf("%w: %w", ErrExecutionRetry, execErr)
+			}
+		}
+
+		batch.Fail(failed, transactionFailure(execErr))
+		for _, leg := range batch.Legs {
+			if _, err := tx.ExecContext(ctx,
+				`update transactions set state = $1, failure_code = $2, failure_reason = $3
+                where id = $4`,
+				leg.State,
+				leg.FailureCode,
+				leg.FailureReason,
+				leg.Id); err != nil {
+				return batch, err
+			}
+		}
+
+		if failed >= 0 {
+			if err := chargeInsufficientFunds(ctx, tx, batch.Legs[failed], execErr); err != nil {
+				return batch, err
+			}
+		}
+	} else {
+		batch.Succeed()
+	}
+
+	for _, leg := range batch.Legs {
+		if err := notifyTransaction(ctx, tx, leg); err != nil {
+			return batch, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update batches set state = $1, failure_code = $2, failure_reason = $3
+        where id = $4`,
+		batch.State,
+		batch.FailureCode,
+		batch.FailureReason,
+		batch.Id); err != nil {
+		return batch, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`delete from batch_queue where batch_id = $1`, batch.Id); err != nil {
+		return batch, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return batch, err
+	}
+
+	return batch, execErr
+}
+
+// assessBatchLeg evaluates the risk rules on a leg, a batch can't be parked
+// as a whole so a leg that needs review is rejected.
+func assessBatchLeg(
+	ctx context.Context,
+	tx *sql.Tx,
+	rules model.RiskRules,
+	leg model.Transaction,
+) error {
+	decision, err := assessRisk(ctx, tx, rules, leg)
+	if err != nil {
+		return err
+	}
+
+	if decision.Action == model.RiskActionReview {
+		return model.NewTransactionError(model.TransactionFailureRiskRejected,
+			"transaction needs review by risk rule %s, batches can't be reviewed",
+			decision.Rule)
+	}
+
+	return nil
+}
@@ -0,0 +1,427 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type CreditRepository struct {
+	db *sql.DB
+}
+
+func NewCrdRepository(db *sql.DB) CreditRepository {
+	return CreditRepository{db}
+}
+
+const creditLineColumns = `service_id, credit_limit, rate, minimum_rate, minimum_amount,
+    grace_days`
+
+func scanCreditLine(row scanner, line *model.CreditLine) error {
+	return row.Scan(
+		&line.Service,
+		&line.Limit,
+		&line.Rate,
+		&line.MinimumRate,
+		&line.MinimumAmount,
+		&line.GraceDays)
+}
+
+const billingCycleColumns = `id, service_id, start_date, end_date, due_date, state,
+    statement, minimum_due, interest, payments, late_fee, late, assessed`
+
+func scanBillingCycle(row scanner, cycle *model.BillingCycle) error {
+	var due sql.NullTime
+	if err := row.Scan(
+		&cycle.Id,
+		&cycle.Service,
+		&cycle.Start,
+		&cycle.End,
+		&due,
+		&cycle.State,
+		&cycle.Statement,
+		&cycle.MinimumDue,
+		&cycle.Interest,
+		&cycle.Payments,
+		&cycle.LateFee,
+		&cycle.Late,
+		&cycle.Assessed); err != nil {
+		return err
+	}
+	cycle.Due = due.Time
+	return nil
+}
+
+func findCreditLine(ctx context.Context, q querier, serviceId uuid.UUID) (model.CreditLine, error) {
+	row := q.QueryRowContext(ctx,
+		`select `+creditLineColumns+` from credit_lines where service_id = $1`, serviceId)
+
+	var line model.CreditLine
+	if err := scanCreditLine(row, &line); err != nil {
+		return model.CreditLine{}, err
+	}
+
+	return line, nil
+}
+
+// SaveCreditLine sets the terms of a line of credit and opens its first
+// billing cycle today, lowering the limit below what is owed only stops new
+// debits. It returns model.ErrCreditNotAllowed for services that are not
+// lines of credit.
+func (repo *CreditRepository) SaveCreditLine(ctx context.Context, line model.CreditLine) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	service, err := lockService(ctx, tx, line.Service)
+	if err != nil {
+		return err
+	}
+
+	if service.Type != model.ServiceTypeLineOfCredit {
+		return model.ErrCreditNotAllowed
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into credit_lines(service_id, credit_limit, rate, minimum_rate, minimum_amount,
+            grace_days)
+        values ($1, $2, $3, $4, $5, $6)
+        on conflict (service_id)
+        do update set credit_limit = excluded.credit_limit, rate = excluded.rate,
+            minimum_rate = excluded.minimum_rate,
+            minimum_amount = excluded.minimum_amount,
+            grace_days = excluded.grace_days`,
+		line.Service,
+		line.Limit,
+		line.Rate,
+		line.MinimumRate,
+		line.MinimumAmount,
+		line.GraceDays); err != nil {
+		return err
+	}
+
+	cycle, err := model.NewBillingCycle(line.Service, model.Today())
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into billing_cycles(id, service_id, start_date, end_date, state)
+        select $1, $2, $3, $4, $5
+        where not exists(select 1 from billing_cycles where service_id = $2)`,
+		cycle.Id,
+		cycle.Service,
+		cycle.Start,
+		cycle.End,
+		cycle.State); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindBilling returns the terms, the open cycle and the last statement of a
+// line of credit. Payments of a statement that was not assessed yet are the
+// ones received so far.
+func (repo *CreditRepository) FindBilling(
+	ctx context.Context, serviceId uuid.UUID,
+) (model.Billing, error) {
+	var billing model.Billing
+
+	line, err := findCreditLine(ctx, repo.db, serviceId)
+	if err != nil {
+		return model.Billing{}, err
+	}
+	billing.Line = line
+
+	row := repo.db.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1`, serviceId)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return model.Billing{}, err
+	}
+	if err := findHeld(ctx, repo.db, &service); err != nil {
+		return model.Billing{}, err
+	}
+	billing.Owed = decimal.Max(service.Balance.Add(service.InitBalance).Neg(), decimal.Zero)
+	billing.Available = decimal.Max(service.Available(), decimal.Zero)
+
+	row = repo.db.QueryRowContext(ctx, `select `+billingCycleColumns+` from billing_cycles
+        where service_id = $1 and state = $2`,
+		serviceId,
+		model.BillingCycleStateOpen)
+	if err := scanBillingCycle(row, &billing.Current); err != nil {
+		return model.Billing{}, err
+	}
+
+	row = repo.db.QueryRowContext(ctx, `select `+billingCycleColumns+` from billing_cycles
+        where service_id = $1 and state = $2
+        order by end_date desc
+        limit 1`,
+		serviceId,
+		model.BillingCycleStateClosed)
+	err = scanBillingCycle(row, &billing.Statement)
+	if errors.Is(err, sql.ErrNoRows) {
+		return billing, nil
+	}
+	if err != nil {
+		return model.Billing{}, err
+	}
+
+	if !billing.Statement.Assessed {
+		billing.Statement.Payments, err = findPayments(ctx, repo.db, serviceId,
+			billing.Statement.End.AddDate(0, 0, 1), billing.Statement.Due.AddDate(0, 0, 1))
+		if err != nil {
+			return model.Billing{}, err
+		}
+	}
+
+	return billing, nil
+}
+
+// findPayments adds up what was credited to a service from the start of day
+// from until right before to.
+func findPayments(
+	ctx context.Context, q querier, serviceId uuid.UUID, from, to time.Time,
+) (decimal.Decimal, error) {
+	row := q.QueryRowContext(ctx, `select coalesce(sum(p.amount), 0) from postings p
+        join journal_entries e on e.id = p.entry_id
+        where p.service_id = $1 and p.amount > 0 and e.time >= $2 and e.time < $3`,
+		serviceId,
+		from,
+		to)
+
+	var payments decimal.Decimal
+	if err := row.Scan(&payments); err != nil {
+		return decimal.Zero, err
+	}
+
+	return payments, nil
+}
+
+// RunBillingCycles assesses the statements that became due and closes the
+// cycles that ended, it returns how many cycles were updated. Cycles are
+// claimed one at a time with skip locked so several api replicas can run
+// this concurrently.
+func (repo *CreditRepository) RunBillingCycles(ctx context.Context) (int, error) {
+	updated := 0
+	for _, next := range []func(context.Context) error{
+		repo.assessNextStatement,
+		repo.closeNextCycle,
+	} {
+		for {
+			err := next(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// assessNextStatement checks the payments of a statement that is due against
+// its minimum payment and charges the late fee when they fall short.
+func (repo *CreditRepository) assessNextStatement(ctx context.Context) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+billingCycleColumns+` from billing_cycles
+        where state = $1 and not assessed and due_date < $2
+        order by due_date
+        limit 1
+        for update skip locked`,
+		model.BillingCycleStateClosed,
+		model.Today())
+
+	var cycle model.BillingCycle
+	if err := scanBillingCycle(row, &cycle); err != nil {
+		return err
+	}
+
+	service, err := lockService(ctx, tx, cycle.Service)
+	if err != nil {
+		return err
+	}
+
+	cycle.Payments, err = findPayments(ctx, tx, cycle.Service,
+		cycle.End.AddDate(0, 0, 1), cycle.Due.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	if remaining := cycle.Remaining(); remaining.IsPositive() {
+		cycle.Late = true
+		cycle.LateFee, err = findFee(ctx, tx, service, model.FeeKindLate, remaining)
+		if err != nil {
+			return err
+		}
+
+		if cycle.LateFee.IsPositive() {
+			if _, err := chargeFee(ctx, tx, service,
+				model.FeeKindLate, cycle.LateFee, uuid.NullUUID{}); err != nil {
+				return err
+			}
+		}
+	}
+	cycle.Assessed = true
+
+	if _, err := tx.ExecContext(ctx, `update billing_cycles
+        set payments = $1, late = $2, late_fee = $3, assessed = $4
+        where id = $5`,
+		cycle.Payments,
+		cycle.Late,
+		cycle.LateFee,
+		cycle.Assessed,
+		cycle.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// closeNextCycle issues the statement of a cycle that ended and opens the
+// next one. Interest is charged on the average daily balance of the cycle
+// unless the previous statement was paid in full before it was due.
+func (repo *CreditRepository) closeNextCycle(ctx context.Context) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+billingCycleColumns+` from billing_cycles
+        where state = $1 and end_date < $2
+        order by end_date
+        limit 1
+        for update skip locked`,
+		model.BillingCycleStateOpen,
+		model.Today())
+
+	var cycle model.BillingCycle
+	if err := scanBillingCycle(row, &cycle); err != nil {
+		return err
+	}
+
+	service, err := lockService(ctx, tx, cycle.Service)
+	if err != nil {
+		return err
+	}
+
+	line, err := findCreditLine(ctx, tx, cycle.Service)
+	if err != nil {
+		return err
+	}
+
+	carried, err := carriesBalance(ctx, tx, cycle.Service)
+	if err != nil {
+		return err
+	}
+
+	total := decimal.Zero
+	owed := decimal.Zero
+	for day := cycle.Start; !day.After(cycle.End); day = day.AddDate(0, 0, 1) {
+		balance, err := findEndOfDayBalance(ctx, tx, service, day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		owed = decimal.Max(balance.Neg(), decimal.Zero)
+		total = total.Add(owed)
+	}
+
+	interest := decimal.Zero
+	if carried {
+		days := cycle.Days()
+		interest = line.Interest(total.Div(decimal.NewFromInt(int64(days))), days)
+	}
+
+	if interest.IsPositive() {
+		income, err := findBankAccount(ctx, tx, model.BankAccountInterestIncome, service.Currency)
+		if err != nil {
+			return err
+		}
+
+		transaction, err := model.NewSettledTransaction(
+			service.Currency, interest, service.Id, income)
+		if err != nil {
+			return err
+		}
+
+		if err := settleTransaction(ctx, tx, &transaction); err != nil {
+			return err
+		}
+	}
+
+	cycle.Close(line, owed.Add(interest), interest)
+	if _, err := tx.ExecContext(ctx, `update billing_cycles
+        set state = $1, due_date = $2, statement = $3, minimum_due = $4, interest = $5
+        where id = $6`,
+		cycle.State,
+		cycle.Due,
+		cycle.Statement,
+		cycle.MinimumDue,
+		cycle.Interest,
+		cycle.Id); err != nil {
+		return err
+	}
+
+	next, err := model.NewBillingCycle(cycle.Service, cycle.End.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into billing_cycles(id, service_id, start_date, end_date, state)
+        values ($1, $2, $3, $4, $5)`,
+		next.Id,
+		next.Service,
+		next.Start,
+		next.End,
+		next.State); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// carriesBalance tells if the last statement of a line of credit was not
+// paid in full before it was due, the first cycle never carries a balance.
+func carriesBalance(ctx context.Context, q querier, serviceId uuid.UUID) (bool, error) {
+	row := q.QueryRowContext(ctx, `select `+billingCycleColumns+` from billing_cycles
+        where service_id = $1 and state = $2
+        order by end_date desc
+        limit 1`,
+		serviceId,
+		model.BillingCycleStateClosed)
+
+	var previous model.BillingCycle
+	if err := scanBillingCycle(row, &previous); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	payments, err := findPayments(ctx, q, serviceId,
+		previous.End.AddDate(0, 0, 1), previous.Due.AddDate(0, 0, 1))
+	if err != nil {
+		return false, err
+	}
+	previous.Payments = payments
+
+	return !previous.PaidInFull(), nil
+}
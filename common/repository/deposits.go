@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type DepositsRepository struct {
+	db *sql.DB
+}
+
+func NewDepRepository(db *sql.DB) DepositsRepository {
+	return DepositsRepository{db}
+}
+
+const depositColumns = `service_id, term, start_date, maturity_date, instruction,
+    payout_service_id, state`
+
+func scanDeposit(row scanner, deposit *model.Deposit) error {
+	return row.Scan(
+		&deposit.Service,
+		&deposit.Term,
+		&deposit.Start,
+		&deposit.Maturity,
+		&deposit.Instruction,
+		&deposit.Payout,
+		&deposit.State)
+}
+
+func findDeposit(ctx context.Context, q querier, serviceId uuid.UUID) (model.Deposit, error) {
+	row := q.QueryRowContext(ctx,
+		`select `+depositColumns+` from deposits where service_id = $1`, serviceId)
+
+	var deposit model.Deposit
+	if err := scanDeposit(row, &deposit); err != nil {
+		return model.Deposit{}, err
+	}
+
+	return deposit, nil
+}
+
+func (repo *DepositsRepository) FindDeposit(
+	ctx context.Context, serviceId uuid.UUID,
+) (model.Deposit, error) {
+	return findDeposit(ctx, repo.db, serviceId)
+}
+
+// checkPayout makes sure the balance of a deposit can be sent to its payout
+// service, which must be owned by an owner of the deposit. It returns
+// model.ErrDepositPayout when it can't.
+func checkPayout(ctx context.Context, q querier, deposit model.Deposit) error {
+	row := q.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1`, deposit.Service)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return err
+	}
+
+	if service.Type != model.ServiceTypeCertificateOfDeposit {
+		return model.ErrDepositNotAllowed
+	}
+
+	if !deposit.Payout.Valid {
+		return nil
+	}
+
+	row = q.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1`, deposit.Payout.UUID)
+
+	var payout model.Service
+	err := scanService(row, &payout)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrDepositPayout
+	}
+	if err != nil {
+		return err
+	}
+
+	if !service.CanPayout(payout) {
+		return model.ErrDepositPayout
+	}
+
+	// the payout has to belong to someone who owns the deposit
+	row = q.QueryRowContext(ctx, `select exists (
+        select 1 from user_service d
+        join user_service p on p.user_id = d.user_id
+        where d.service_id = $1 and p.service_id = $2)`,
+		deposit.Service,
+		deposit.Payout.UUID)
+
+	var owned bool
+	if err := row.Scan(&owned); err != nil {
+		return err
+	}
+	if !owned {
+		return model.ErrDepositPayout
+	}
+	return nil
+}
+
+// SaveDeposit starts the term of a deposit, a new term can only start once
+// the previous one matured. It returns model.ErrDepositNotAllowed for
+// services that are not certificates of deposit and model.ErrDepositOpen
+// while a term is running.
+func (repo *DepositsRepository) SaveDeposit(ctx context.Context, deposit model.Deposit) error {
+	if err := checkPayout(ctx, repo.db, deposit); err != nil {
+		return err
+	}
+
+	result, err := repo.db.ExecContext(ctx, `insert
+        into deposits(service_id, term, start_date, maturity_date, instruction,
+            payout_service_id, state)
+        values ($1, $2, $3, $4, $5, $6, $7)
+        on conflict (service_id)
+        do update set term = excluded.term, start_date = excluded.start_date,
+            maturity_date = excluded.maturity_date, instruction = excluded.instruction,
+            payout_service_id = excluded.payout_service_id, state = excluded.state
+        where deposits.state = $8`,
+		deposit.Service,
+		deposit.Term,
+		deposit.Start,
+		deposit.Maturity,
+		deposit.Instruction,
+		deposit.Payout,
+		deposit.State,
+		model.DepositStateMatured)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return model.ErrDepositOpen
+	}
+	return nil
+}
+
+// SaveDepositInstruction changes what happens to a deposit that is still
+// running when it matures.
+func (repo *DepositsRepository) SaveDepositInstruction(
+	ctx context.Context, deposit model.Deposit,
+) error {
+	if err := checkPayout(ctx, repo.db, deposit); err != nil {
+		return err
+	}
+
+	result, err := repo.db.ExecContext(ctx, `update deposits
+        set instruction = $1, payout_service_id = $2
+        where service_id = $3 and state = $4`,
+		deposit.Instruction,
+		deposit.Payout,
+		deposit.Service,
+		model.DepositStateOpen)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// findEarlyWithdrawalFee returns the penalty a transaction pays to debit a
+// deposit before it matures, transactions that did not accept it fail.
+// Services without a running term are not penalized.
+func findEarlyWithdrawalFee(
+	ctx context.Context,
+	q querier,
+	service model.Service,
+	transaction model.Transaction,
+) (decimal.Decimal, error) {
+	if service.Type != model.ServiceTypeCertificateOfDeposit {
+		return decimal.Zero, nil
+	}
+
+	deposit, err := findDeposit(ctx, q, service.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return decimal.Zero, nil
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if deposit.Matured(model.Today()) {
+		return decimal.Zero, nil
+	}
+
+	if !transaction.AcceptPenalty {
+		return decimal.Zero, model.NewTransactionError(model.TransactionFailureDepositNotMatured,
+			"deposit %s does not mature until %s", service.Id,
+			deposit.Maturity.Format(time.DateOnly))
+	}
+
+	return findFee(ctx, q, service, model.FeeKindEarlyWithdrawal, transaction.Amount)
+}
+
+// MatureDeposits rolls over or pays out every deposit whose term is over, it
+// returns how many deposits matured. Deposits are claimed one at a time with
+// skip locked so several api replicas can run this concurrently.
+func (repo *DepositsRepository) MatureDeposits(ctx context.Context) (int, error) {
+	matured := 0
+	for {
+		err := repo.matureNextDeposit(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return matured, nil
+		}
+		if err != nil {
+			return matured, err
+		}
+		matured++
+	}
+}
+
+// matureNextDeposit settles a deposit that reached its maturity date. Deposits
+// that can't be paid out, because the service is not active or the payout
+// service can't take the balance anymore, are left matured so their owner can
+// withdraw without penalty.
+func (repo *DepositsRepository) matureNextDeposit(ctx context.Context) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	today := model.Today()
+	row := tx.QueryRowContext(ctx, `select `+depositColumns+` from deposits
+        where state = $1 and maturity_date <= $2
+        order by maturity_date
+        limit 1
+        for update skip locked`,
+		model.DepositStateOpen,
+		today)
+
+	var deposit model.Deposit
+	if err := scanDeposit(row, &deposit); err != nil {
+		return err
+	}
+
+	ids := []uuid.UUID{deposit.Service}
+	if deposit.Payout.Valid {
+		ids = append(ids, deposit.Payout.UUID)
+	}
+
+	services, err := lockServices(ctx, tx, ids...)
+	if err != nil {
+		return err
+	}
+
+	service := services[deposit.Service]
+	if deposit.Instruction == model.DepositInstructionRollover &&
+		service.State == model.ServiceStateActive {
+		for deposit.Matured(today) {
+			deposit.Rollover()
+		}
+	} else {
+		deposit.State = model.DepositStateMatured
+	}
+
+	payout, ok := services[deposit.Payout.UUID]
+	if deposit.Instruction == model.DepositInstructionPayout && ok &&
+		service.State == model.ServiceStateActive &&
+		payout.State == model.ServiceStateActive &&
+		payout.CheckPermissions(model.ServicePermissionCredit) &&
+		service.CanPayout(payout) {
+		if amount := service.Available(); amount.IsPositive() {
+			transaction, err := model.NewSettledTransaction(
+				service.Currency, amount, service.Id, payout.Id)
+			if err != nil {
+				return err
+			}
+
+			if err := settleTransaction(ctx, tx, &transaction); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `update deposits
+        set start_date = $1, maturity_date = $2, state = $3
+        where service_id = $4`,
+		deposit.Start,
+		deposit.Maturity,
+		deposit.State,
+		deposit.Service); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type FeesRepository struct {
+	db *sql.DB
+}
+
+func NewFeeRepository(db *sql.DB) FeesRepository {
+	return FeesRepository{db}
+}
+
+const feeRuleColumns = `id, service_type, kind, currency, amount, rate`
+
+func scanFeeRule(row scanner, rule *model.FeeRule) error {
+	return row.Scan(
+		&rule.Id,
+		&rule.ServiceType,
+		&rule.Kind,
+		&rule.Currency,
+		&rule.Amount,
+		&rule.Rate)
+}
+
+// SaveFeeRule stores the rule, replacing the amounts of the rule that already
+// exists for the same service type, kind and currency. The id of the stored
+// rule is written back.
+func (repo *FeesRepository) SaveFeeRule(ctx context.Context, rule *model.FeeRule) error {
+	row := repo.db.QueryRowContext(ctx, `insert
+        into fee_rules(id, service_type, kind, currency, amount, rate)
+        values ($1, $2, $3, $4, $5, $6)
+        on conflict (service_type, kind, currency)
+        do update set amount = excluded.amount, rate = excluded.rate
+        returning id`,
+		rule.Id,
+		rule.ServiceType,
+		rule.Kind,
+		rule.Currency,
+		rule.Amount,
+		rule.Rate)
+
+	return row.Scan(&rule.Id)
+}
+
+func (repo *FeesRepository) FindFeeRules(
+	ctx context.Context,
+	cursor uuid.UUID,
+) (iter.Seq2[model.FeeRule, error], error) {
+	query := "select " + feeRuleColumns + " from fee_rules"
+	params := make([]interface{}, 0, 1)
+
+	if (cursor != uuid.UUID{}) {
+		query += " where id > $1"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.FeeRule, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var rule model.FeeRule
+			err := scanFeeRule(rows, &rule)
+
+			if !yield(rule, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+func (repo *FeesRepository) DeleteFeeRule(ctx context.Context, id uuid.UUID) error {
+	result, err := repo.db.ExecContext(ctx, `delete from fee_rules where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// findFee returns the fee of the given kind a service pays on amount, it is
+// zero when there is no rule for the service.
+func findFee(
+	ctx context.Context,
+	q querier,
+	service model.Service,
+	kind string,
+	amount decimal.Decimal,
+) (decimal.Decimal, error) {
+	row := q.QueryRowContext(ctx, `select `+feeRuleColumns+` from fee_rules
+        where (service_type, kind, currency) = ($1, $2, $3)`,
+		service.Type,
+		kind,
+		service.Currency)
+
+	var rule model.FeeRule
+	if err := scanFeeRule(row, &rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return decimal.Zero, nil
+		}
+		return decimal.Zero, err
+	}
+
+	return rule.Compute(amount), nil
+}
+
+// chargeFee moves amount from the service to the fee income account of the
+// bank as a transaction of its own. Fees are charged even if they overdraw
+// the service, callers are expected to hold a lock on it.
+func chargeFee(
+	ctx context.Context,
+	tx *sql.Tx,
+	service model.Service,
+	kind string,
+	amount decimal.Decimal,
+	charges uuid.NullUUID,
+) (model.Transaction, error) {
+	income, err := findBankAccount(ctx, tx, model.BankAccountFeeIncome, service.Currency)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	fee, err := model.NewFee(kind, service.Currency, amount, service.Id, income, charges)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	if err := settleTransaction(ctx, tx, &fee); err != nil {
+		return model.Transaction{}, err
+	}
+
+	return fee, nil
+}
+
+// chargeInsufficientFunds charges the NSF fee to the source of a transaction
+// that failed because it could not cover the amount.
+func chargeInsufficientFunds(
+	ctx context.Context,
+	tx *sql.Tx,
+	transaction model.Transaction,
+	execErr error,
+) error {
+	var trsErr *model.TransactionError
+	if !errors.As(execErr, &trsErr) ||
+		trsErr.Code != model.TransactionFailureInsufficientFunds ||
+		!transaction.Chargeable() {
+		return nil
+	}
+
+	services, err := lockServices(ctx, tx, transaction.Source)
+	if err != nil {
+		return err
+	}
+
+	service, ok := services[transaction.Source]
+	if !ok {
+		return nil
+	}
+
+	amount, err := findFee(ctx, tx, service, model.FeeKindInsufficientFunds, transaction.Amount)
+	if err != nil || !amount.IsPositive() {
+		return err
+	}
+
+	_, err = chargeFee(ctx, tx, service, model.FeeKindInsufficientFunds, amount,
+		uuid.NullUUID{UUID: transaction.Id, Valid: true})
+	return err
+}
+
+// ChargeMaintenanceFees charges the monthly maintenance fee to every active
+// service that was not charged yet this month and returns how many were
+// charged. Services are claimed one at a time with skip locked so several api
+// replicas can run this concurrently.
+func (repo *FeesRepository) ChargeMaintenanceFees(ctx context.Context) (int, error) {
+	charged := 0
+	period := model.MaintenancePeriod(model.Today())
+	for {
+		ok, err := repo.chargeNextMaintenanceFee(ctx, period)
+		if errors.Is(err, sql.ErrNoRows) {
+			return charged, nil
+		}
+		if err != nil {
+			return charged, err
+		}
+		if ok {
+			charged++
+		}
+	}
+}
+
+func (repo *FeesRepository) chargeNextMaintenanceFee(
+	ctx context.Context, period time.Time,
+) (bool, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+serviceColumns+` from services
+        where state = $1
+        and exists(select 1 from fee_rules r
+            where (r.service_type, r.kind, r.currency) = (services.type, $2, services.currency))
+        and not exists(select 1 from maintenance_fees m
+            where m.service_id = services.id and m.period = $3)
+        limit 1
+        for no key update skip locked`,
+		model.ServiceStateActive,
+		model.FeeKindMaintenance,
+		period)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return false, err
+	}
+
+	amount, err := findFee(ctx, tx, service, model.FeeKindMaintenance, decimal.Zero)
+	if err != nil {
+		return false, err
+	}
+
+	fee, err := chargeFee(ctx, tx, service, model.FeeKindMaintenance, amount, uuid.NullUUID{})
+	if err != nil {
+		return false, err
+	}
+
+	// the service may have been charged by another replica that committed
+	// after this one read maintenance_fees, its row wins and this charge is
+	// rolled back
+	result, err := tx.ExecContext(ctx, `insert
+        into maintenance_fees(service_id, period, transaction_id)
+        values ($1, $2, $3)
+        on conflict (service_id, period) do nothing`,
+		service.Id,
+		period,
+		fee.Id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows != 1 {
+		return false, nil
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type FxRepository struct {
+	db *sql.DB
+}
+
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+func NewFxRepository(db *sql.DB) FxRepository {
+	return FxRepository{db}
+}
+
+func (repo *FxRepository) StoreRates(ctx context.Context, rates []model.ExchangeRate) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		if _, err := tx.ExecContext(ctx, `insert
+            into exchange_rates(base, currency, rate, time)
+            values ($1, $2, $3, $4)
+            on conflict (base, currency, time) do update set rate = excluded.rate`,
+			rate.Base,
+			rate.Currency,
+			rate.Rate,
+			rate.Time); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (repo *FxRepository) FindRate(
+	ctx context.Context, source, target string,
+) (decimal.Decimal, error) {
+	return findRate(ctx, repo.db, source, target)
+}
+
+// findRate computes the rate between two currencies from the latest rates of
+// any base they are both quoted against.
+func findRate(
+	ctx context.Context, q querier, source, target string,
+) (decimal.Decimal, error) {
+	if source == target {
+		return decimal.NewFromInt(1), nil
+	}
+
+	// when several bases quote both currencies the one with the freshest pair
+	// of rates wins, ties go to the first base in alphabetical order
+	row := q.QueryRowContext(ctx, `with rates as (
+            (select distinct on (base, currency) base, currency, rate, time
+            from exchange_rates
+            where time <= now()
+            order by base, currency, time desc)
+            union all
+            (select base, base, 1, max(time) from exchange_rates
+            where time <= now()
+            group by base)
+        )
+        select s.rate, t.rate
+        from rates s
+        join rates t on t.base = s.base
+        where s.currency = $1 and t.currency = $2
+        order by least(s.time, t.time) desc, s.base
+        limit 1`,
+		source, target)
+
+	var sourceRate, targetRate decimal.Decimal
+	if err := row.Scan(&sourceRate, &targetRate); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return decimal.Decimal{}, ErrRateUnavailable
+		}
+		return decimal.Decimal{}, err
+	}
+
+	return targetRate.DivRound(sourceRate, 10), nil
+}
+
+func (repo *FxRepository) CreateQuote(
+	ctx context.Context, source, target string,
+) (model.Quote, error) {
+	rate, err := repo.FindRate(ctx, source, target)
+	if err != nil {
+		return model.Quote{}, err
+	}
+
+	quote, err := model.NewQuote(source, target, rate)
+	if err != nil {
+		return model.Quote{}, err
+	}
+
+	if _, err := repo.db.ExecContext(ctx, `insert
+        into fx_quotes(id, source, target, rate, expires)
+        values ($1, $2, $3, $4, $5)`,
+		quote.Id,
+		quote.Source,
+		quote.Target,
+		quote.Rate,
+		quote.Expires); err != nil {
+		return model.Quote{}, err
+	}
+
+	return quote, nil
+}
+
+// findQuotedRate returns the rate of a quote that was still valid when the
+// transaction was created.
+func findQuotedRate(
+	ctx context.Context, q querier, quoteId, transactionId uuid.UUID, source, target string,
+) (decimal.Decimal, error) {
+	row := q.QueryRowContext(ctx,
+		`select rate from fx_quotes
+        where id = $1 and source = $2 and target = $3
+        and expires >= (select time from transactions where id = $4)`,
+		quoteId, source, target, transactionId)
+
+	var rate decimal.Decimal
+	if err := row.Scan(&rate); err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	return rate, nil
+}
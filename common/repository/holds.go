@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type HoldsRepository struct {
+	db *sql.DB
+}
+
+var ErrServiceNotActive = errors.New("service is not active")
+
+func NewHldRepository(db *sql.DB) HoldsRepository {
+	return HoldsRepository{db}
+}
+
+const holdColumns = "id, service_id, amount, captured, state, time, expires"
+
+func scanHold(row scanner, hold *model.Hold) error {
+	return row.Scan(
+		&hold.Id,
+		&hold.Service,
+		&hold.Amount,
+		&hold.Captured,
+		&hold.State,
+		&hold.Time,
+		&hold.Expires)
+}
+
+// CreateHold reserves funds on the service, the service is locked so the
+// available balance can't change while the hold is placed.
+func (repo *HoldsRepository) CreateHold(ctx context.Context, hold model.Hold) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	service, err := lockService(ctx, tx, hold.Service)
+	if err != nil {
+		return err
+	}
+
+	if service.State != model.ServiceStateActive {
+		return ErrServiceNotActive
+	}
+
+	if err := service.Reserve(hold.Amount); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into holds(id, service_id, amount, captured, state, time, expires)
+        values ($1, $2, $3, $4, $5, $6, $7)`,
+		hold.Id,
+		hold.Service,
+		hold.Amount,
+		hold.Captured,
+		hold.State,
+		hold.Time,
+		hold.Expires); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *HoldsRepository) FindServiceHolds(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Hold, error], error) {
+	query := "select " + holdColumns + " from holds"
+	params := make([]interface{}, 0, 2)
+
+	query += " where service_id = $1"
+	params = append(params, serviceId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Hold, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var hold model.Hold
+			err := scanHold(rows, &hold)
+
+			if !yield(hold, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+// lockHold reads a hold and whether it has a capture waiting to execute, the
+// lock keeps a second capture from being queued next to it.
+func lockHold(ctx context.Context, tx *sql.Tx, serviceId, id uuid.UUID) (model.Hold, error) {
+	row := tx.QueryRowContext(ctx,
+		`select `+holdColumns+` from holds where id = $1 and service_id = $2 for update`,
+		id, serviceId)
+
+	var hold model.Hold
+	if err := scanHold(row, &hold); err != nil {
+		return model.Hold{}, err
+	}
+
+	row = tx.QueryRowContext(ctx, `select exists (
+        select 1 from transactions where hold = $1 and state in ($2, $3))`,
+		hold.Id,
+		model.TransactionStateProcessing,
+		model.TransactionStateReview)
+	if err := row.Scan(&hold.Pending); err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold queues a transaction for amount from the held service to the
+// destination, the hold is marked captured when the transaction succeeds.
+func (repo *HoldsRepository) CaptureHold(
+	ctx context.Context,
+	serviceId, id uuid.UUID,
+	amount decimal.Decimal,
+	destination uuid.UUID,
+) (model.Transaction, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	defer tx.Rollback()
+
+	hold, err := lockHold(ctx, tx, serviceId, id)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	row := tx.QueryRowContext(ctx, `select currency from services where id = $1`, serviceId)
+
+	var currency string
+	if err := row.Scan(&currency); err != nil {
+		return model.Transaction{}, err
+	}
+
+	transaction, err := hold.Capture(currency, amount, destination)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	if err := enqueueTransaction(ctx, tx, &transaction); err != nil {
+		return model.Transaction{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Transaction{}, err
+	}
+
+	return transaction, nil
+}
+
+func (repo *HoldsRepository) VoidHold(ctx context.Context, serviceId, id uuid.UUID) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	hold, err := lockHold(ctx, tx, serviceId, id)
+	if err != nil {
+		return err
+	}
+
+	if err := hold.Void(); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update holds set state = $1 where id = $2`, hold.State, hold.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExpireHolds marks holds past their expiry, they already stopped counting
+// towards the held amount so this only keeps their state accurate.
+func (repo *HoldsRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	result, err := repo.db.ExecContext(ctx,
+		`update holds set state = $1 where state = $2 and expires <= now()`,
+		model.HoldStateExpired,
+		model.HoldStateActive)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+var (
+	ErrIdempotencyConflict   = errors.New("idempotency key was used with a different request")
+	ErrIdempotencyInProgress = errors.New("request with idempotency key is still in progress")
+)
+
+func NewIdmRepository(db *sql.DB) IdempotencyRepository {
+	return IdempotencyRepository{db}
+}
+
+// BeginRequest reserves the key for the request. If the key was already used
+// by an identical request that finished, the stored request is returned and
+// the caller should replay its response. Keys left in progress for more than
+// a minute are considered abandoned and can be claimed again.
+func (repo *IdempotencyRepository) BeginRequest(
+	ctx context.Context, req model.IdempotentRequest,
+) (model.IdempotentRequest, error) {
+	row := repo.db.QueryRowContext(ctx, `insert
+        into idempotency_keys(user_id, key, request_hash)
+        values ($1, $2, $3)
+        on conflict (user_id, key) do update set time = now()
+        where idempotency_keys.status is null
+        and idempotency_keys.request_hash = excluded.request_hash
+        and idempotency_keys.time < now() - interval '1 minute'
+        returning true`,
+		req.User,
+		req.Key,
+		req.Hash)
+
+	var claimed bool
+	err := row.Scan(&claimed)
+	if err == nil {
+		return req, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return model.IdempotentRequest{}, err
+	}
+
+	row = repo.db.QueryRowContext(ctx,
+		`select user_id, key, request_hash, coalesce(status, 0), content_type,
+            coalesce(response, '')
+        from idempotency_keys
+        where (user_id, key) = ($1, $2)`,
+		req.User,
+		req.Key)
+
+	var stored model.IdempotentRequest
+	if err := row.Scan(
+		&stored.User,
+		&stored.Key,
+		&stored.Hash,
+		&stored.Status,
+		&stored.ContentType,
+		&stored.Response); err != nil {
+		return model.IdempotentRequest{}, err
+	}
+
+	if stored.Hash != req.Hash {
+		return model.IdempotentRequest{}, ErrIdempotencyConflict
+	}
+	if !stored.Completed() {
+		return model.IdempotentRequest{}, ErrIdempotencyInProgress
+	}
+
+	return stored, nil
+}
+
+func (repo *IdempotencyRepository) CompleteRequest(
+	ctx context.Context, req model.IdempotentRequest,
+) error {
+	if _, err := repo.db.ExecContext(ctx,
+		`update idempotency_keys set status = $1, content_type = $2, response = $3
+        where (user_id, key) = ($4, $5)`,
+		req.Status,
+		req.ContentType,
+		req.Response,
+		req.User,
+		req.Key); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseRequest frees the key so the request can be attempted again.
+func (repo *IdempotencyRepository) ReleaseRequest(
+	ctx context.Context, req model.IdempotentRequest,
+) error {
+	if _, err := repo.db.ExecContext(ctx,
+		`delete from idempotency_keys where (user_id, key) = ($1, $2)`,
+		req.User,
+		req.Key); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type InterestRepository struct {
+	db *sql.DB
+}
+
+func NewIntRepository(db *sql.DB) InterestRepository {
+	return InterestRepository{db}
+}
+
+const interestColumns = `service_id, rate, day_count, accrued, accrued_through`
+
+func scanInterest(row scanner, interest *model.Interest) error {
+	return row.Scan(
+		&interest.Service,
+		&interest.Rate,
+		&interest.DayCount,
+		&interest.Accrued,
+		&interest.AccruedThrough)
+}
+
+func (repo *InterestRepository) FindInterest(
+	ctx context.Context, serviceId uuid.UUID,
+) (model.Interest, error) {
+	row := repo.db.QueryRowContext(ctx,
+		`select `+interestColumns+` from service_interest where service_id = $1`, serviceId)
+
+	var interest model.Interest
+	if err := scanInterest(row, &interest); err != nil {
+		return model.Interest{}, err
+	}
+
+	return interest, nil
+}
+
+// SaveInterest sets the rate and day count convention of a service, the new
+// rate applies to every day that was not accrued yet. It returns
+// model.ErrInterestNotAllowed for services that can't earn interest.
+func (repo *InterestRepository) SaveInterest(ctx context.Context, interest model.Interest) error {
+	row := repo.db.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1`, interest.Service)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return err
+	}
+
+	if !service.EarnsInterest() {
+		return model.ErrInterestNotAllowed
+	}
+
+	if _, err := repo.db.ExecContext(ctx, `insert
+        into service_interest(service_id, rate, day_count, accrued, accrued_through)
+        values ($1, $2, $3, $4, $5)
+        on conflict (service_id)
+        do update set rate = excluded.rate, day_count = excluded.day_count`,
+		interest.Service,
+		interest.Rate,
+		interest.DayCount,
+		interest.Accrued,
+		interest.AccruedThrough); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AccrueInterest accrues the interest of every day that ended since the last
+// run and pays it at the end of every month, it returns how many services
+// were brought up to date. Services are claimed one at a time with skip
+// locked so several api replicas can run this concurrently.
+func (repo *InterestRepository) AccrueInterest(ctx context.Context) (int, error) {
+	accrued := 0
+	yesterday := model.Today().AddDate(0, 0, -1)
+	for {
+		err := repo.accrueNextInterest(ctx, yesterday)
+		if errors.Is(err, sql.ErrNoRows) {
+			return accrued, nil
+		}
+		if err != nil {
+			return accrued, err
+		}
+		accrued++
+	}
+}
+
+func (repo *InterestRepository) accrueNextInterest(ctx context.Context, through time.Time) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+interestColumns+` from service_interest
+        where accrued_through < $1
+        order by accrued_through
+        limit 1
+        for update skip locked`, through)
+
+	var interest model.Interest
+	if err := scanInterest(row, &interest); err != nil {
+		return err
+	}
+
+	service, err := lockService(ctx, tx, interest.Service)
+	if err != nil {
+		return err
+	}
+
+	for interest.AccruedThrough.Before(through) {
+		// closed services and services that were never opened don't earn
+		// anything, their days are skipped
+		balance := decimal.Zero
+		if service.State == model.ServiceStateActive || service.State == model.ServiceStateFrozen {
+			balance, err = findEndOfDayBalance(ctx, tx, service,
+				interest.AccruedThrough.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+		}
+		interest.Accrue(balance)
+
+		if interest.Due() {
+			if err := payInterest(ctx, tx, service, interest.Capitalize()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update service_interest set accrued = $1, accrued_through = $2 where service_id = $3`,
+		interest.Accrued,
+		interest.AccruedThrough,
+		interest.Service); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findEndOfDayBalance returns the balance the service had right before end.
+func findEndOfDayBalance(
+	ctx context.Context, q querier, service model.Service, end time.Time,
+) (decimal.Decimal, error) {
+	row := q.QueryRowContext(ctx, `select coalesce((
+        select p.balance from postings p
+        join journal_entries e on e.id = p.entry_id
+        where p.service_id = $1 and e.time < $2
+        order by p.id desc
+        limit 1), 0)`,
+		service.Id,
+		end)
+
+	var balance decimal.Decimal
+	if err := row.Scan(&balance); err != nil {
+		return decimal.Zero, err
+	}
+
+	return balance.Add(service.InitBalance), nil
+}
+
+// payInterest moves amount from the interest expense account of the bank to
+// the service.
+func payInterest(
+	ctx context.Context, tx *sql.Tx, service model.Service, amount decimal.Decimal,
+) error {
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	expense, err := findBankAccount(ctx, tx, model.BankAccountInterestExpense, service.Currency)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := model.NewSettledTransaction(service.Currency, amount, expense, service.Id)
+	if err != nil {
+		return err
+	}
+
+	return settleTransaction(ctx, tx, &transaction)
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type LedgerRepository struct {
+	db *sql.DB
+}
+
+func NewLdgRepository(db *sql.DB) LedgerRepository {
+	return LedgerRepository{db}
+}
+
+// postJournalEntry stores the entry and applies every posting to the balance
+// of its service. Callers are expected to hold a lock on the services.
+func postJournalEntry(ctx context.Context, tx *sql.Tx, entry *model.JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	row := tx.QueryRowContext(ctx, `insert
+        into journal_entries(id, transaction_id, time)
+        values ($1, $2, $3)
+        returning time`,
+		entry.Id,
+		entry.Transaction,
+		entry.Time)
+	if err := row.Scan(&entry.Time); err != nil {
+		return err
+	}
+
+	// balances are updated in service order for the same reason services are
+	// locked in order, the bank accounts touched here are shared by everyone
+	order := make([]int, len(entry.Postings))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return bytes.Compare(entry.Postings[a].Service[:], entry.Postings[b].Service[:])
+	})
+
+	for _, i := range order {
+		posting := &entry.Postings[i]
+		posting.Time = entry.Time
+
+		row := tx.QueryRowContext(ctx,
+			`update services set balance = balance + $1 where id = $2 returning balance`,
+			posting.Amount,
+			posting.Service)
+		if err := row.Scan(&posting.Balance); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `insert
+            into postings(id, entry_id, service_id, currency, amount, balance)
+            values ($1, $2, $3, $4, $5, $6)`,
+			posting.Id,
+			posting.Entry,
+			posting.Service,
+			posting.Currency,
+			posting.Amount,
+			posting.Balance); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *LedgerRepository) FindServiceEntries(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Posting, error], error) {
+	query := `select p.id, p.entry_id, e.transaction_id, e.time, p.service_id,
+        p.currency, p.amount, p.balance
+        from postings p
+        join journal_entries e on e.id = p.entry_id`
+	params := make([]interface{}, 0, 2)
+
+	query += " where p.service_id = $1"
+	params = append(params, serviceId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and p.id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by p.id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Posting, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var posting model.Posting
+			err := rows.Scan(
+				&posting.Id,
+				&posting.Entry,
+				&posting.Transaction,
+				&posting.Time,
+				&posting.Service,
+				&posting.Currency,
+				&posting.Amount,
+				&posting.Balance)
+
+			if !yield(posting, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+func (repo *LedgerRepository) ReconcileService(
+	ctx context.Context, serviceId uuid.UUID,
+) (model.Reconciliation, error) {
+	row := repo.db.QueryRowContext(ctx,
+		`select s.id, s.balance, coalesce(sum(p.amount), 0), count(p.id)
+        from services s
+        left join postings p on p.service_id = s.id
+        where s.id = $1
+        group by s.id, s.balance`, serviceId)
+
+	var rec model.Reconciliation
+	if err := row.Scan(
+		&rec.Service,
+		&rec.Balance,
+		&rec.Ledger,
+		&rec.Postings); err != nil {
+		return model.Reconciliation{}, err
+	}
+
+	return rec, nil
+}
+
+// FindStatement reads the postings of a service in a period along with the
+// balance before and after it.
+func (repo *LedgerRepository) FindStatement(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	from time.Time,
+	to time.Time,
+) (model.Statement, error) {
+	var serviceType, currency string
+	var initBalance, posted decimal.Decimal
+	row := repo.db.QueryRowContext(ctx,
+		`select type, currency, init_balance from services where id = $1`, serviceId)
+	if err := row.Scan(&serviceType, &currency, &initBalance); err != nil {
+		return model.Statement{}, err
+	}
+
+	row = repo.db.QueryRowContext(ctx, `select coalesce((
+        select p.balance from postings p
+        join journal_entries e on e.id = p.entry_id
+        where p.service_id = $1 and e.time < $2
+        order by e.time desc, p.id desc
+        limit 1), 0)`,
+		serviceId,
+		from)
+	if err := row.Scan(&posted); err != nil {
+		return model.Statement{}, err
+	}
+	statement := model.NewStatement(serviceId, serviceType, currency,
+		from, to, initBalance, posted)
+
+	rows, err := repo.db.QueryContext(ctx, `select p.id, e.transaction_id, e.time,
+        p.amount, p.balance,
+        case when p.amount < 0 then t.destination else t.source end
+        from postings p
+        join journal_entries e on e.id = p.entry_id
+        join transactions t on t.id = e.transaction_id
+        where p.service_id = $1 and e.time >= $2 and e.time < $3
+        order by e.time, p.id`,
+		serviceId,
+		from,
+		to)
+	if err != nil {
+		return model.Statement{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line model.StatementLine
+		if err := rows.Scan(
+			&line.Id,
+			&line.Transaction,
+			&line.Time,
+			&line.Amount,
+			&line.Balance,
+			&line.Counterparty); err != nil {
+			return model.Statement{}, err
+		}
+		statement.AddLine(line)
+	}
+
+	return statement, rows.Err()
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type LimitsRepository struct {
+	db *sql.DB
+}
+
+func NewLimRepository(db *sql.DB) LimitsRepository {
+	return LimitsRepository{db}
+}
+
+// findLimits returns the limits of a service, a service without limits gets
+// the zero value.
+func findLimits(ctx context.Context, q querier, serviceId uuid.UUID) (model.Limits, error) {
+	row := q.QueryRowContext(ctx, `select service_id, max_amount, daily_amount,
+        monthly_amount, daily_count
+        from service_limits where service_id = $1`, serviceId)
+
+	var limits model.Limits
+	err := row.Scan(
+		&limits.Service,
+		&limits.MaxAmount,
+		&limits.DailyAmount,
+		&limits.MonthlyAmount,
+		&limits.DailyCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Limits{Service: serviceId}, nil
+	}
+	if err != nil {
+		return model.Limits{}, err
+	}
+
+	return limits, nil
+}
+
+// findLimitUsage sums the successful outgoing transactions of a service that
+// were executed in the current day and month, the source must be locked for
+// the result to stay valid.
+func findLimitUsage(ctx context.Context, q querier, serviceId uuid.UUID) (model.LimitUsage, error) {
+	row := q.QueryRowContext(ctx, `select
+        coalesce(sum(amount) filter (where executed >= date_trunc('day', now())), 0),
+        coalesce(sum(amount), 0),
+        count(*) filter (where executed >= date_trunc('day', now()))
+        from transactions
+        where source = $1 and state = $2 and fee = ''
+        and executed >= date_trunc('month', now())`,
+		serviceId,
+		model.TransactionStateSuccess)
+
+	var usage model.LimitUsage
+	if err := row.Scan(
+		&usage.DailyAmount,
+		&usage.MonthlyAmount,
+		&usage.DailyCount); err != nil {
+		return model.LimitUsage{}, err
+	}
+
+	return usage, nil
+}
+
+// checkLimits fails if the transaction would exceed the limits of its
+// source.
+func checkLimits(ctx context.Context, tx *sql.Tx, transaction model.Transaction) error {
+	limits, err := findLimits(ctx, tx, transaction.Source)
+	if err != nil {
+		return err
+	}
+
+	usage, err := findLimitUsage(ctx, tx, transaction.Source)
+	if err != nil {
+		return err
+	}
+
+	return limits.Check(transaction.Amount, usage)
+}
+
+func (repo *LimitsRepository) FindLimits(
+	ctx context.Context,
+	serviceId uuid.UUID,
+) (model.Limits, model.Allowance, error) {
+	limits, err := findLimits(ctx, repo.db, serviceId)
+	if err != nil {
+		return model.Limits{}, model.Allowance{}, err
+	}
+
+	usage, err := findLimitUsage(ctx, repo.db, serviceId)
+	if err != nil {
+		return model.Limits{}, model.Allowance{}, err
+	}
+
+	return limits, limits.Remaining(usage), nil
+}
+
+func (repo *LimitsRepository) UpdateLimits(ctx context.Context, limits model.Limits) error {
+	_, err := repo.db.ExecContext(ctx, `insert
+        into service_limits(service_id, max_amount, daily_amount, monthly_amount, daily_count)
+        values ($1, $2, $3, $4, $5)
+        on conflict (service_id) do update set
+        max_amount = excluded.max_amount,
+        daily_amount = excluded.daily_amount,
+        monthly_amount = excluded.monthly_amount,
+        daily_count = excluded.daily_count`,
+		limits.Service,
+		limits.MaxAmount,
+		limits.DailyAmount,
+		limits.MonthlyAmount,
+		limits.DailyCount)
+
+	return err
+}
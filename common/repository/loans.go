@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type LoansRepository struct {
+	db *sql.DB
+}
+
+func NewLoaRepository(db *sql.DB) LoansRepository {
+	return LoansRepository{db}
+}
+
+const installmentColumns = `number, due, interest, principal, late_fee, paid_interest,
+    paid_principal, paid_late_fee, late, state`
+
+func scanInstallment(row scanner, installment *model.Installment) error {
+	return row.Scan(
+		&installment.Number,
+		&installment.Due,
+		&installment.Interest,
+		&installment.Principal,
+		&installment.LateFee,
+		&installment.PaidInterest,
+		&installment.PaidPrincipal,
+		&installment.PaidLateFee,
+		&installment.Late,
+		&installment.State)
+}
+
+func findLoan(ctx context.Context, q querier, serviceId uuid.UUID) (model.Loan, error) {
+	row := q.QueryRowContext(ctx, `select service_id, principal, rate, term, frequency,
+        disbursement_id, originated
+        from loans where service_id = $1`, serviceId)
+
+	var loan model.Loan
+	var originated sql.NullTime
+	if err := row.Scan(
+		&loan.Service,
+		&loan.Principal,
+		&loan.Rate,
+		&loan.Term,
+		&loan.Frequency,
+		&loan.Disbursement,
+		&originated); err != nil {
+		return model.Loan{}, err
+	}
+	loan.Originated = originated.Time
+
+	rows, err := q.QueryContext(ctx, `select `+installmentColumns+` from loan_installments
+        where service_id = $1 order by number`, serviceId)
+	if err != nil {
+		return model.Loan{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var installment model.Installment
+		if err := scanInstallment(rows, &installment); err != nil {
+			return model.Loan{}, err
+		}
+		loan.Installments = append(loan.Installments, installment)
+	}
+
+	return loan, rows.Err()
+}
+
+func (repo *LoansRepository) FindLoan(ctx context.Context, serviceId uuid.UUID) (model.Loan, error) {
+	return findLoan(ctx, repo.db, serviceId)
+}
+
+// SaveLoan sets the terms of a loan, they can only change until the loan is
+// originated. It returns model.ErrLoanNotAllowed for services that are not
+// loans.
+func (repo *LoansRepository) SaveLoan(ctx context.Context, loan model.Loan) error {
+	row := repo.db.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1`, loan.Service)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return err
+	}
+
+	if service.Type != model.ServiceTypeLoan {
+		return model.ErrLoanNotAllowed
+	}
+
+	result, err := repo.db.ExecContext(ctx, `insert
+        into loans(service_id, principal, rate, term, frequency, disbursement_id)
+        values ($1, $2, $3, $4, $5, $6)
+        on conflict (service_id)
+        do update set principal = excluded.principal, rate = excluded.rate,
+            term = excluded.term, frequency = excluded.frequency,
+            disbursement_id = excluded.disbursement_id
+        where loans.originated is null`,
+		loan.Service,
+		loan.Principal,
+		loan.Rate,
+		loan.Term,
+		loan.Frequency,
+		loan.Disbursement)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return model.ErrLoanOriginated
+	}
+	return nil
+}
+
+// originateLoan generates the schedule of the loan of a service that was
+// just activated and disburses the principal, the service can't be debited
+// by its owner afterwards. Services without loan terms or with a loan that
+// was already originated are left alone.
+func originateLoan(ctx context.Context, tx *sql.Tx, serviceId uuid.UUID) error {
+	loan, err := findLoan(ctx, tx, serviceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !loan.Originated.IsZero() {
+		return nil
+	}
+
+	services, err := lockServices(ctx, tx, loan.Service, loan.Disbursement)
+	if err != nil {
+		return err
+	}
+
+	service := services[loan.Service]
+	disbursement, ok := services[loan.Disbursement]
+	if !ok || disbursement.State != model.ServiceStateActive ||
+		disbursement.Currency != service.Currency {
+		return model.ErrLoanDisbursement
+	}
+
+	if err := loan.Originate(model.Today()); err != nil {
+		return err
+	}
+
+	for _, installment := range loan.Installments {
+		if _, err := tx.ExecContext(ctx, `insert
+            into loan_installments(service_id, number, due, interest, principal, state)
+            values ($1, $2, $3, $4, $5, $6)`,
+			loan.Service,
+			installment.Number,
+			installment.Due,
+			installment.Interest,
+			installment.Principal,
+			installment.State); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update loans set originated = $1 where service_id = $2`,
+		loan.Originated,
+		loan.Service); err != nil {
+		return err
+	}
+
+	change, err := model.NewPermissionChange(loan.Service, uuid.NullUUID{},
+		model.ServicePermissionNameDebit, false, "loan originated")
+	if err != nil {
+		return err
+	}
+
+	if err := changeServicePermission(ctx, tx, &service, change); err != nil {
+		return err
+	}
+
+	transaction, err := model.NewSettledTransaction(
+		service.Currency, loan.Principal, loan.Service, loan.Disbursement)
+	if err != nil {
+		return err
+	}
+
+	return settleTransaction(ctx, tx, &transaction)
+}
+
+// repayLoan splits a transaction that was credited to a loan, the interest
+// is moved to the interest income account of the bank so only the late fees
+// and principal reduce what the service owes. Callers are expected to hold a
+// lock on the service.
+func repayLoan(
+	ctx context.Context,
+	tx *sql.Tx,
+	service model.Service,
+	transaction model.Transaction,
+) error {
+	loan, err := findLoan(ctx, tx, service.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if loan.Originated.IsZero() {
+		return nil
+	}
+
+	before := make([]model.Installment, len(loan.Installments))
+	copy(before, loan.Installments)
+
+	allocation := loan.Repay(transaction.SettledAmount, model.Today())
+
+	for i, installment := range loan.Installments {
+		if installment.State == before[i].State &&
+			installment.PaidInterest.Equal(before[i].PaidInterest) &&
+			installment.PaidPrincipal.Equal(before[i].PaidPrincipal) &&
+			installment.PaidLateFee.Equal(before[i].PaidLateFee) {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `update loan_installments
+            set paid_interest = $1, paid_principal = $2, paid_late_fee = $3, state = $4
+            where service_id = $5 and number = $6`,
+			installment.PaidInterest,
+			installment.PaidPrincipal,
+			installment.PaidLateFee,
+			installment.State,
+			loan.Service,
+			installment.Number); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into loan_repayments(transaction_id, service_id, late_fee, interest, principal)
+        values ($1, $2, $3, $4, $5)`,
+		transaction.Id,
+		loan.Service,
+		allocation.LateFee,
+		allocation.Interest,
+		allocation.Principal); err != nil {
+		return err
+	}
+
+	if !allocation.Interest.IsPositive() {
+		return nil
+	}
+
+	income, err := findBankAccount(ctx, tx, model.BankAccountInterestIncome, service.Currency)
+	if err != nil {
+		return err
+	}
+
+	interest, err := model.NewSettledTransaction(
+		service.Currency, allocation.Interest, service.Id, income)
+	if err != nil {
+		return err
+	}
+
+	return settleTransaction(ctx, tx, &interest)
+}
+
+// ChargeLateFees assesses the late fee of every installment that is overdue
+// and returns how many were assessed, the fee is added to the installment.
+// Loans are claimed through their service one at a time with skip locked so
+// several api replicas can run this concurrently.
+func (repo *LoansRepository) ChargeLateFees(ctx context.Context) (int, error) {
+	charged := 0
+	for {
+		n, err := repo.chargeNextLateFees(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return charged, nil
+		}
+		if err != nil {
+			return charged, err
+		}
+		charged += n
+	}
+}
+
+func (repo *LoansRepository) chargeNextLateFees(ctx context.Context) (int, error) {
+	today := model.Today()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+serviceColumns+` from services
+        where id in (select service_id from loan_installments
+            where state = $1 and not late and due < $2)
+        limit 1
+        for no key update skip locked`,
+		model.InstallmentStatePending,
+		today)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return 0, err
+	}
+	serviceId := service.Id
+
+	// the installments are read again now that the service is locked, the
+	// ones another replica marked late in the meantime are not charged twice
+	rows, err := tx.QueryContext(ctx, `select `+installmentColumns+` from loan_installments
+        where service_id = $1 and state = $2 and not late and due < $3
+        order by number`,
+		serviceId,
+		model.InstallmentStatePending,
+		today)
+	if err != nil {
+		return 0, err
+	}
+
+	var overdue []model.Installment
+	for rows.Next() {
+		var installment model.Installment
+		if err := scanInstallment(rows, &installment); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		overdue = append(overdue, installment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, installment := range overdue {
+		owed := installment.Interest.Add(installment.Principal).
+			Sub(installment.PaidInterest).Sub(installment.PaidPrincipal)
+
+		amount, err := findFee(ctx, tx, service, model.FeeKindLate, owed)
+		if err != nil {
+			return 0, err
+		}
+
+		if amount.IsPositive() {
+			if _, err := chargeFee(ctx, tx, service,
+				model.FeeKindLate, amount, uuid.NullUUID{}); err != nil {
+				return 0, err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `update loan_installments
+            set late = true, late_fee = $1
+            where service_id = $2 and number = $3`,
+			amount,
+			serviceId,
+			installment.Number); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(overdue), tx.Commit()
+}
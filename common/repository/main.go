@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx, it lets helpers run
+// either inside or outside of a database transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type OverdraftsRepository struct {
+	db *sql.DB
+}
+
+func NewOvdRepository(db *sql.DB) OverdraftsRepository {
+	return OverdraftsRepository{db}
+}
+
+const overdraftColumns = `service_id, overdraft_limit, nsf_policy, rate, accrued,
+    accrued_through`
+
+func scanOverdraft(row scanner, overdraft *model.Overdraft) error {
+	if err := row.Scan(
+		&overdraft.Service,
+		&overdraft.Limit,
+		&overdraft.NSFPolicy,
+		&overdraft.Interest.Rate,
+		&overdraft.Interest.Accrued,
+		&overdraft.Interest.AccruedThrough); err != nil {
+		return err
+	}
+
+	overdraft.Interest.Service = overdraft.Service
+	overdraft.Interest.DayCount = model.DayCountActual365
+	return nil
+}
+
+func (repo *OverdraftsRepository) FindOverdraft(
+	ctx context.Context, serviceId uuid.UUID,
+) (model.Overdraft, error) {
+	row := repo.db.QueryRowContext(ctx,
+		`select `+overdraftColumns+` from overdrafts where service_id = $1`, serviceId)
+
+	var overdraft model.Overdraft
+	if err := scanOverdraft(row, &overdraft); err != nil {
+		return model.Overdraft{}, err
+	}
+
+	return overdraft, nil
+}
+
+// SaveOverdraft grants an overdraft or changes its terms, interest accrued so
+// far is kept. It returns model.ErrOverdraftNotAllowed for services that
+// can't be overdrawn.
+func (repo *OverdraftsRepository) SaveOverdraft(
+	ctx context.Context, overdraft model.Overdraft,
+) error {
+	row := repo.db.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1`, overdraft.Service)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return err
+	}
+
+	if !service.AllowsOverdraft() {
+		return model.ErrOverdraftNotAllowed
+	}
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the overdraft is written before the service is locked, the same order
+	// the interest job locks them in
+	if _, err := tx.ExecContext(ctx, `insert
+        into overdrafts(service_id, overdraft_limit, nsf_policy, rate, accrued,
+            accrued_through)
+        values ($1, $2, $3, $4, $5, $6)
+        on conflict (service_id)
+        do update set overdraft_limit = excluded.overdraft_limit,
+            nsf_policy = excluded.nsf_policy, rate = excluded.rate`,
+		overdraft.Service,
+		overdraft.Limit,
+		overdraft.NSFPolicy,
+		overdraft.Interest.Rate,
+		overdraft.Interest.Accrued,
+		overdraft.Interest.AccruedThrough); err != nil {
+		return err
+	}
+
+	service, err = lockService(ctx, tx, overdraft.Service)
+	if err != nil {
+		return err
+	}
+
+	change, err := model.NewPermissionChange(overdraft.Service, uuid.NullUUID{},
+		model.ServicePermissionNameOverdraft, true, "overdraft granted")
+	if err != nil {
+		return err
+	}
+
+	if err := changeServicePermission(ctx, tx, &service, change); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteOverdraft revokes the overdraft of a service, a balance that is
+// already overdrawn stays owed but no new debits can overdraw it. Interest
+// accrued and not charged yet is waived.
+func (repo *OverdraftsRepository) DeleteOverdraft(ctx context.Context, serviceId uuid.UUID) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `delete from overdrafts where service_id = $1`, serviceId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return sql.ErrNoRows
+	}
+
+	service, err := lockService(ctx, tx, serviceId)
+	if err != nil {
+		return err
+	}
+
+	change, err := model.NewPermissionChange(serviceId, uuid.NullUUID{},
+		model.ServicePermissionNameOverdraft, false, "overdraft revoked")
+	if err != nil {
+		return err
+	}
+
+	if err := changeServicePermission(ctx, tx, &service, change); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AccrueOverdraftInterest accrues the interest of every day that ended since
+// the last run on overdrawn balances and charges it at the end of every
+// month, it returns how many overdrafts were brought up to date. Overdrafts
+// are claimed one at a time with skip locked so several api replicas can run
+// this concurrently.
+func (repo *OverdraftsRepository) AccrueOverdraftInterest(ctx context.Context) (int, error) {
+	accrued := 0
+	yesterday := model.Today().AddDate(0, 0, -1)
+	for {
+		err := repo.accrueNextOverdraft(ctx, yesterday)
+		if errors.Is(err, sql.ErrNoRows) {
+			return accrued, nil
+		}
+		if err != nil {
+			return accrued, err
+		}
+		accrued++
+	}
+}
+
+func (repo *OverdraftsRepository) accrueNextOverdraft(
+	ctx context.Context, through time.Time,
+) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+overdraftColumns+` from overdrafts
+        where accrued_through < $1
+        order by accrued_through
+        limit 1
+        for update skip locked`, through)
+
+	var overdraft model.Overdraft
+	if err := scanOverdraft(row, &overdraft); err != nil {
+		return err
+	}
+
+	service, err := lockService(ctx, tx, overdraft.Service)
+	if err != nil {
+		return err
+	}
+
+	for overdraft.Interest.AccruedThrough.Before(through) {
+		balance := decimal.Zero
+		if service.State == model.ServiceStateActive || service.State == model.ServiceStateFrozen {
+			balance, err = findEndOfDayBalance(ctx, tx, service,
+				overdraft.Interest.AccruedThrough.AddDate(0, 0, 2))
+			if err != nil {
+				return err
+			}
+		}
+		overdraft.Accrue(balance)
+
+		if overdraft.Interest.Due() {
+			if err := chargeOverdraftInterest(ctx, tx, service,
+				overdraft.Interest.Capitalize()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update overdrafts set accrued = $1, accrued_through = $2 where service_id = $3`,
+		overdraft.Interest.Accrued,
+		overdraft.Interest.AccruedThrough,
+		overdraft.Service); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// chargeOverdraftInterest moves amount from the service to the interest
+// income account of the bank, even if it overdraws the service further.
+func chargeOverdraftInterest(
+	ctx context.Context, tx *sql.Tx, service model.Service, amount decimal.Decimal,
+) error {
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	income, err := findBankAccount(ctx, tx, model.BankAccountInterestIncome, service.Currency)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := model.NewSettledTransaction(service.Currency, amount, service.Id, income)
+	if err != nil {
+		return err
+	}
+
+	return settleTransaction(ctx, tx, &transaction)
+}
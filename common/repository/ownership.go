@@ -62,3 +62,40 @@ func (repo *OwnershipRepository) CheckTransactionOwnership(
 	}
 	return nil
 }
+
+func (repo *OwnershipRepository) CheckBatchOwnership(
+	ctx context.Context, batchId, userId uuid.UUID,
+) error {
+	row := repo.db.QueryRowContext(ctx, `select exists(
+        select 1 from user_service us
+        join transactions t
+        on us.service_id = t.source
+        where us.user_id = $1
+        and t.batch = $2)`, userId, batchId)
+	var owns bool
+	if err := row.Scan(&owns); err != nil {
+		return err
+	}
+
+	if !owns {
+		return ErrOwnership
+	}
+	return nil
+}
+
+func (repo *OwnershipRepository) CheckWebhookOwnership(
+	ctx context.Context, webhookId, userId uuid.UUID,
+) error {
+	row := repo.db.QueryRowContext(ctx, `select exists(
+        select 1 from webhooks where id = $1 and user_id = $2)`,
+		webhookId, userId)
+	var owns bool
+	if err := row.Scan(&owns); err != nil {
+		return err
+	}
+
+	if !owns {
+		return ErrOwnership
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+// ImportPayments creates a transaction for every pending instruction that
+// passes validation, rejected instructions get a reason instead. Accepted
+// transactions are queued together when the import commits. Users that are
+// not tellers can only send from services they own.
+func (repo *TransactionsRepository) ImportPayments(
+	ctx context.Context,
+	payments *model.PaymentImport,
+	user model.User,
+) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range payments.Instructions {
+		instruction := &payments.Instructions[i]
+		if instruction.Status != model.PaymentStatusPending {
+			continue
+		}
+
+		if err := importPayment(ctx, tx, instruction, user); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func importPayment(
+	ctx context.Context,
+	tx *sql.Tx,
+	instruction *model.PaymentInstruction,
+	user model.User,
+) error {
+	var srcState, srcCurrency string
+	var owned bool
+	row := tx.QueryRowContext(ctx, `select state, currency,
+        exists(select 1 from user_service where user_id = $2 and service_id = services.id)
+        from services where id = $1`,
+		instruction.Source,
+		user.Id)
+	if err := row.Scan(&srcState, &srcCurrency, &owned); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			instruction.Reject(model.PaymentReasonInvalidAccount,
+				"debtor account does not exist")
+			return nil
+		}
+		return err
+	}
+
+	if !owned && user.Clearance < model.UserClearanceTeller {
+		instruction.Reject(model.PaymentReasonNotAllowed,
+			"debtor account is not owned by the initiating party")
+		return nil
+	}
+
+	if srcState != model.ServiceStateActive {
+		instruction.Reject(model.PaymentReasonClosedAccount, "debtor account is not active")
+		return nil
+	}
+
+	if instruction.Currency != srcCurrency {
+		instruction.Reject(model.PaymentReasonInvalidCurrency,
+			"currency does not match the debtor account")
+		return nil
+	}
+
+	var dstState string
+	row = tx.QueryRowContext(ctx,
+		`select state from services where id = $1`, instruction.Destination)
+	if err := row.Scan(&dstState); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			instruction.Reject(model.PaymentReasonInvalidAccount,
+				"creditor account does not exist")
+			return nil
+		}
+		return err
+	}
+
+	if dstState != model.ServiceStateActive {
+		instruction.Reject(model.PaymentReasonClosedAccount, "creditor account is not active")
+		return nil
+	}
+
+	transaction, err := model.NewTransaction(instruction.Currency, instruction.Amount,
+		instruction.Source, instruction.Destination)
+	if err != nil {
+		return err
+	}
+
+	if err := enqueueTransaction(ctx, tx, &transaction); err != nil {
+		return err
+	}
+	instruction.Accept(transaction.Id)
+
+	return nil
+}
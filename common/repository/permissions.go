@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type PermissionsRepository struct {
+	db *sql.DB
+}
+
+func NewPrmRepository(db *sql.DB) PermissionsRepository {
+	return PermissionsRepository{db}
+}
+
+const permissionChangeColumns = `id, service_id, user_id, permission, granted, reason, time`
+
+func scanPermissionChange(row scanner, change *model.PermissionChange) error {
+	return row.Scan(
+		&change.Id,
+		&change.Service,
+		&change.User,
+		&change.Permission,
+		&change.Granted,
+		&change.Reason,
+		&change.Time)
+}
+
+// changeServicePermission applies change to a service locked by the caller
+// and records it, changes that leave the permissions as they were are not
+// recorded.
+func changeServicePermission(
+	ctx context.Context,
+	tx *sql.Tx,
+	service *model.Service,
+	change model.PermissionChange,
+) error {
+	changed, err := service.ApplyPermissionChange(change)
+	if err != nil || !changed {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update services set permissions = $1 where id = $2`,
+		service.Permissions,
+		service.Id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into service_permission_changes(id, service_id, user_id, permission, granted, reason,
+            time)
+        values ($1, $2, $3, $4, $5, $6, $7)`,
+		change.Id,
+		change.Service,
+		change.User,
+		change.Permission,
+		change.Granted,
+		change.Reason,
+		change.Time); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ChangeServicePermission grants or revokes a permission of a service.
+func (repo *PermissionsRepository) ChangeServicePermission(
+	ctx context.Context, change model.PermissionChange,
+) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	service, err := lockService(ctx, tx, change.Service)
+	if err != nil {
+		return err
+	}
+
+	if err := changeServicePermission(ctx, tx, &service, change); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (repo *PermissionsRepository) FindPermissionChanges(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.PermissionChange, error], error) {
+	query := "select " + permissionChangeColumns + " from service_permission_changes"
+	params := make([]interface{}, 0, 2)
+
+	query += " where service_id = $1"
+	params = append(params, serviceId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.PermissionChange, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var change model.PermissionChange
+			err := scanPermissionChange(rows, &change)
+
+			if !yield(change, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+const (
+	// Number of times a queue entry is executed before a transient error is
+	// treated as a failure
+	maxExecutionAttempts = 5
+	// Delay before the first retry in seconds, it doubles on every attempt
+	retryBaseDelay = 1
+)
+
+var ErrExecutionRetry = errors.New("execution failed and will be retried")
+
+// isTransient reports whether err is a deadlock or serialization failure,
+// those go away by running the same work again.
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == "40P01" || pgErr.Code == "40001"
+}
+
+// retryQueueEntry pushes a queue entry back with exponential backoff, it
+// returns false without touching the entry if it ran out of attempts.
+func retryQueueEntry(
+	ctx context.Context,
+	tx *sql.Tx,
+	queue string,
+	column string,
+	id any,
+) (bool, error) {
+	row := tx.QueryRowContext(ctx, `update `+queue+`
+        set attempts = attempts + 1,
+        available = now() + make_interval(secs => $1 * power(2, attempts))
+        where `+column+` = $2 and attempts + 1 < $3
+        returning attempts`,
+		retryBaseDelay,
+		id,
+		maxExecutionAttempts)
+
+	var attempts int
+	if err := row.Scan(&attempts); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TransactionEventsChannel is the Postgres channel where state changes of
+// transactions are notified, the payload is the id and the new state.
+const TransactionEventsChannel = "transaction_events"
+
+// notifyTransaction announces the state of a transaction to every listener
+// and webhook, nothing is sent unless the database transaction commits.
+func notifyTransaction(ctx context.Context, q querier, transaction model.Transaction) error {
+	if _, err := q.ExecContext(ctx, `select pg_notify($1, $2)`,
+		TransactionEventsChannel,
+		transaction.Id.String()+" "+transaction.State); err != nil {
+		return err
+	}
+
+	return enqueueTransactionEvent(ctx, q, transaction)
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// riskFacts looks up the history of a transaction for the risk rules.
+type riskFacts struct {
+	ctx         context.Context
+	q           querier
+	transaction model.Transaction
+}
+
+func (facts *riskFacts) NewPayee() (bool, error) {
+	row := facts.q.QueryRowContext(facts.ctx, `select not exists(
+        select 1 from transactions
+        where source = $1 and destination = $2 and state = $3 and id <> $4)`,
+		facts.transaction.Source,
+		facts.transaction.Destination,
+		model.TransactionStateSuccess,
+		facts.transaction.Id)
+
+	var newPayee bool
+	if err := row.Scan(&newPayee); err != nil {
+		return false, err
+	}
+
+	return newPayee, nil
+}
+
+func (facts *riskFacts) Velocity(window time.Duration) (int, decimal.Decimal, error) {
+	row := facts.q.QueryRowContext(facts.ctx, `select count(*), coalesce(sum(amount), 0)
+        from transactions
+        where source = $1 and state <> $2 and id <> $3 and fee = ''
+        and time >= now() - make_interval(secs => $4)`,
+		facts.transaction.Source,
+		model.TransactionStateError,
+		facts.transaction.Id,
+		window.Seconds())
+
+	var count int
+	var amount decimal.Decimal
+	if err := row.Scan(&count, &amount); err != nil {
+		return 0, decimal.Decimal{}, err
+	}
+
+	return count, amount, nil
+}
+
+// assessRisk evaluates the risk rules on a transaction, a rejection is
+// returned as a transaction error. Reversals are started by tellers and
+// transactions that were already approved in review are always allowed.
+func assessRisk(
+	ctx context.Context,
+	q querier,
+	rules model.RiskRules,
+	transaction model.Transaction,
+) (model.RiskDecision, error) {
+	allow := model.RiskDecision{Action: model.RiskActionAllow}
+	if len(rules) == 0 || transaction.Reverses.Valid {
+		return allow, nil
+	}
+
+	row := q.QueryRowContext(ctx, `select exists(
+        select 1 from transaction_reviews where transaction_id = $1 and decision = $2)`,
+		transaction.Id,
+		model.ReviewDecisionApproved)
+
+	var approved bool
+	if err := row.Scan(&approved); err != nil {
+		return model.RiskDecision{}, err
+	}
+	if approved {
+		return allow, nil
+	}
+
+	decision, err := rules.Evaluate(transaction, time.Now(),
+		&riskFacts{ctx, q, transaction})
+	if err != nil {
+		return model.RiskDecision{}, err
+	}
+
+	if decision.Action == model.RiskActionReject {
+		return decision, model.NewTransactionError(model.TransactionFailureRiskRejected,
+			"transaction rejected by risk rule %s", decision.Rule)
+	}
+
+	return decision, nil
+}
+
+// parkTransaction takes a transaction out of processing until a teller
+// reviews it.
+func parkTransaction(
+	ctx context.Context,
+	tx *sql.Tx,
+	transaction *model.Transaction,
+	decision model.RiskDecision,
+) error {
+	transaction.State = model.TransactionStateReview
+
+	if _, err := tx.ExecContext(ctx,
+		`update transactions set state = $1 where id = $2`,
+		transaction.State,
+		transaction.Id); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `insert
+        into transaction_reviews(transaction_id, rule, time)
+        values ($1, $2, now())`,
+		transaction.Id,
+		decision.Rule)
+
+	return err
+}
+
+// FindPendingReviews lists the transactions waiting for a teller in the order
+// they were parked.
+func (repo *TransactionsRepository) FindPendingReviews(
+	ctx context.Context,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Review, error], error) {
+	query := `select transaction_id, rule, time, coalesce(decision, ''), reviewer
+        from transaction_reviews where decision is null`
+	params := make([]interface{}, 0, 1)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and transaction_id > $1"
+		params = append(params, cursor)
+	}
+
+	query += " order by transaction_id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Review, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var review model.Review
+			err := rows.Scan(
+				&review.Transaction,
+				&review.Rule,
+				&review.Time,
+				&review.Decision,
+				&review.Reviewer)
+
+			if !yield(review, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+// ReviewTransaction records the decision of a teller on a parked transaction,
+// an approved transaction goes back to the queue and skips the risk rules, a
+// declined one fails.
+func (repo *TransactionsRepository) ReviewTransaction(
+	ctx context.Context,
+	id uuid.UUID,
+	reviewer uuid.UUID,
+	approve bool,
+) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx,
+		`select `+transactionColumns+` from transactions where id = $1 for update`, id)
+
+	var transaction model.Transaction
+	if err := scanTransaction(row, &transaction); err != nil {
+		return err
+	}
+
+	if transaction.State != model.TransactionStateReview {
+		return model.ErrTransactionNotInReview
+	}
+
+	decision := model.ReviewDecisionDeclined
+	if approve {
+		decision = model.ReviewDecisionApproved
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update transaction_reviews set decision = $1, reviewer = $2
+        where transaction_id = $3`,
+		decision,
+		reviewer,
+		transaction.Id); err != nil {
+		return err
+	}
+
+	if approve {
+		transaction.State = model.TransactionStateProcessing
+		if _, err := tx.ExecContext(ctx,
+			`update transactions set state = $1 where id = $2`,
+			transaction.State,
+			transaction.Id); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`insert into transaction_queue(transaction_id) values ($1)`,
+			transaction.Id); err != nil {
+			return err
+		}
+	} else {
+		transaction.Fail(model.NewTransactionError(model.TransactionFailureReviewDeclined,
+			"transaction was declined in review"))
+		if _, err := tx.ExecContext(ctx,
+			`update transactions set state = $1, failure_code = $2, failure_reason = $3
+            where id = $4`,
+			transaction.State,
+			transaction.FailureCode,
+			transaction.FailureReason,
+			transaction.Id); err != nil {
+			return err
+		}
+	}
+
+	if err := notifyTransaction(ctx, tx, transaction); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,332 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type SchedulesRepository struct {
+	db *sql.DB
+}
+
+func NewSchRepository(db *sql.DB) SchedulesRepository {
+	return SchedulesRepository{db}
+}
+
+const scheduleColumns = `id, service_id, destination, currency, amount, frequency, state,
+    start, next_run, end_date, max_runs, runs`
+
+func scanSchedule(row scanner, schedule *model.Schedule) error {
+	var end sql.NullTime
+	if err := row.Scan(
+		&schedule.Id,
+		&schedule.Service,
+		&schedule.Destination,
+		&schedule.Currency,
+		&schedule.Amount,
+		&schedule.Frequency,
+		&schedule.State,
+		&schedule.Start,
+		&schedule.NextRun,
+		&end,
+		&schedule.MaxRuns,
+		&schedule.Runs); err != nil {
+		return err
+	}
+
+	schedule.End = end.Time
+	return nil
+}
+
+func scheduleEnd(schedule model.Schedule) sql.NullTime {
+	return sql.NullTime{Time: schedule.End, Valid: !schedule.End.IsZero()}
+}
+
+func (repo *SchedulesRepository) CreateSchedule(
+	ctx context.Context, schedule model.Schedule,
+) error {
+	if _, err := repo.db.ExecContext(ctx, `insert
+        into schedules(id, service_id, destination, currency, amount, frequency, state,
+            start, next_run, end_date, max_runs, runs)
+        values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		schedule.Id,
+		schedule.Service,
+		schedule.Destination,
+		schedule.Currency,
+		schedule.Amount,
+		schedule.Frequency,
+		schedule.State,
+		schedule.Start,
+		schedule.NextRun,
+		scheduleEnd(schedule),
+		schedule.MaxRuns,
+		schedule.Runs); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SchedulesRepository) FindSchedule(
+	ctx context.Context, serviceId, id uuid.UUID,
+) (model.Schedule, error) {
+	row := repo.db.QueryRowContext(ctx,
+		`select `+scheduleColumns+` from schedules where id = $1 and service_id = $2`,
+		id, serviceId)
+
+	var schedule model.Schedule
+	if err := scanSchedule(row, &schedule); err != nil {
+		return model.Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (repo *SchedulesRepository) FindServiceSchedules(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Schedule, error], error) {
+	query := "select " + scheduleColumns + " from schedules"
+	params := make([]interface{}, 0, 2)
+
+	query += " where service_id = $1"
+	params = append(params, serviceId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Schedule, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var schedule model.Schedule
+			err := scanSchedule(rows, &schedule)
+
+			if !yield(schedule, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+// UpdateSchedule changes the amount and limits of an active schedule.
+func (repo *SchedulesRepository) UpdateSchedule(
+	ctx context.Context, schedule model.Schedule,
+) error {
+	result, err := repo.db.ExecContext(ctx,
+		`update schedules set amount = $1, end_date = $2, max_runs = $3, state = $4
+        where id = $5 and service_id = $6 and state = $7`,
+		schedule.Amount,
+		scheduleEnd(schedule),
+		schedule.MaxRuns,
+		schedule.State,
+		schedule.Id,
+		schedule.Service,
+		model.ScheduleStateActive)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return fmt.Errorf("%d rows changed", rows)
+	}
+	return nil
+}
+
+func (repo *SchedulesRepository) FindScheduleExecutions(
+	ctx context.Context,
+	scheduleId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.ScheduleExecution, error], error) {
+	query := `select e.id, e.schedule_id, e.time, e.transaction_id, e.outcome, e.reason,
+        coalesce(t.state::text, ''), coalesce(t.failure_code, '')
+        from schedule_executions e
+        left join transactions t on t.id = e.transaction_id`
+	params := make([]interface{}, 0, 2)
+
+	query += " where e.schedule_id = $1"
+	params = append(params, scheduleId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and e.id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by e.id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.ScheduleExecution, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var execution model.ScheduleExecution
+			err := rows.Scan(
+				&execution.Id,
+				&execution.Schedule,
+				&execution.Time,
+				&execution.Transaction,
+				&execution.Outcome,
+				&execution.Reason,
+				&execution.TransactionState,
+				&execution.FailureCode)
+
+			if !yield(execution, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+// RunDueSchedules enqueues a transaction for every schedule that is due and
+// returns how many ran. Schedules are claimed one at a time with skip locked
+// so several api replicas can run this concurrently.
+func (repo *SchedulesRepository) RunDueSchedules(ctx context.Context) (int, error) {
+	ran := 0
+	for {
+		err := repo.runNextSchedule(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
+		}
+		ran++
+	}
+}
+
+func (repo *SchedulesRepository) runNextSchedule(ctx context.Context) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+scheduleColumns+` from schedules
+        where state = $1 and next_run <= now()
+        order by next_run
+        limit 1
+        for update skip locked`,
+		model.ScheduleStateActive)
+
+	var schedule model.Schedule
+	if err := scanSchedule(row, &schedule); err != nil {
+		return err
+	}
+
+	// runs missed while the scheduler was down are skipped, only the latest
+	// one that is due pays
+	now := time.Now()
+	for schedule.Missed(now) {
+		skipped, err := model.NewScheduleExecution(schedule.Id)
+		if err != nil {
+			return err
+		}
+		skipped.Outcome = model.ScheduleOutcomeFailed
+		skipped.Reason = "run was missed and skipped"
+
+		if err := insertScheduleExecution(ctx, tx, skipped); err != nil {
+			return err
+		}
+		schedule.Advance()
+	}
+
+	execution, err := model.NewScheduleExecution(schedule.Id)
+	if err != nil {
+		return err
+	}
+
+	// schedules that ran out of runs are completed without paying again
+	if schedule.Exhausted() {
+		if _, err := tx.ExecContext(ctx,
+			`update schedules set next_run = $1, runs = $2, state = $3 where id = $4`,
+			schedule.NextRun,
+			schedule.Runs,
+			model.ScheduleStateCompleted,
+			schedule.Id); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	transaction, err := model.NewTransaction(
+		schedule.Currency, schedule.Amount, schedule.Service, schedule.Destination)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "savepoint enqueue"); err != nil {
+		return err
+	}
+
+	if err := enqueueTransaction(ctx, tx, &transaction); err != nil {
+		log.Printf("schedule %s could not enqueue transaction: %s\n", schedule.Id, err)
+		if _, err := tx.ExecContext(ctx, "rollback to savepoint enqueue"); err != nil {
+			return err
+		}
+		execution.Outcome = model.ScheduleOutcomeFailed
+		execution.Reason = "transaction could not be created"
+	} else {
+		execution.Transaction = uuid.NullUUID{UUID: transaction.Id, Valid: true}
+	}
+
+	if err := insertScheduleExecution(ctx, tx, execution); err != nil {
+		return err
+	}
+
+	schedule.Advance()
+	if _, err := tx.ExecContext(ctx,
+		`update schedules set next_run = $1, runs = $2, state = $3 where id = $4`,
+		schedule.NextRun,
+		schedule.Runs,
+		schedule.State,
+		schedule.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertScheduleExecution(
+	ctx context.Context, q querier, execution model.ScheduleExecution,
+) error {
+	_, err := q.ExecContext(ctx, `insert
+        into schedule_executions(id, schedule_id, time, transaction_id, outcome, reason)
+        values ($1, $2, $3, $4, $5, $6)`,
+		execution.Id,
+		execution.Schedule,
+		execution.Time,
+		execution.Transaction,
+		execution.Outcome,
+		execution.Reason)
+	return err
+}
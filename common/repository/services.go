@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"iter"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/ndfsa/cardboard-bank/common/model"
@@ -18,6 +20,89 @@ func NewSrvRepository(db *sql.DB) ServicesRepository {
 	return ServicesRepository{db}
 }
 
+const serviceColumns = `id, type, state, permissions, currency, init_balance, balance,
+    coalesce((select c.credit_limit from credit_lines c where c.service_id = services.id), 0),
+    coalesce((select o.overdraft_limit from overdrafts o where o.service_id = services.id), 0),
+    coalesce((select o.nsf_policy::text from overdrafts o where o.service_id = services.id),
+        'REJ')`
+
+func scanService(row scanner, service *model.Service) error {
+	return row.Scan(
+		&service.Id,
+		&service.Type,
+		&service.State,
+		&service.Permissions,
+		&service.Currency,
+		&service.InitBalance,
+		&service.Balance,
+		&service.CreditLimit,
+		&service.OverdraftLimit,
+		&service.NSFPolicy)
+}
+
+// findHeld adds up the active holds of a service and the captures that are
+// still waiting to be executed or reviewed, a hold with a pending capture
+// only reserves the captured amount.
+func findHeld(ctx context.Context, q querier, service *model.Service) error {
+	return q.QueryRowContext(ctx, `select
+        (select coalesce(sum(h.amount), 0) from holds h
+            where h.service_id = $1 and h.state = $2 and h.expires > now()
+            and not exists (select 1 from transactions p
+                where p.hold = h.id and p.state in ($3, $4)))
+        + (select coalesce(sum(t.amount), 0) from transactions t
+            where t.source = $1 and t.hold is not null and t.state in ($3, $4))`,
+		service.Id,
+		model.HoldStateActive,
+		model.TransactionStateProcessing,
+		model.TransactionStateReview).Scan(&service.Held)
+}
+
+// lockService reads a service and locks it until the end of the transaction.
+func lockService(ctx context.Context, tx *sql.Tx, id uuid.UUID) (model.Service, error) {
+	row := tx.QueryRowContext(ctx,
+		`select `+serviceColumns+` from services where id = $1 for no key update`, id)
+
+	var service model.Service
+	if err := scanService(row, &service); err != nil {
+		return model.Service{}, err
+	}
+
+	if err := findHeld(ctx, tx, &service); err != nil {
+		return model.Service{}, err
+	}
+
+	return service, nil
+}
+
+// lockServices locks every service in ascending id order, so two transactions
+// touching the same services always wait on each other in the same order and
+// never deadlock. Services that don't exist are left out of the result.
+func lockServices(
+	ctx context.Context,
+	tx *sql.Tx,
+	ids ...uuid.UUID,
+) (map[uuid.UUID]model.Service, error) {
+	sorted := slices.Clone(ids)
+	slices.SortFunc(sorted, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	sorted = slices.Compact(sorted)
+
+	services := make(map[uuid.UUID]model.Service, len(sorted))
+	for _, id := range sorted {
+		service, err := lockService(ctx, tx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		services[id] = service
+	}
+
+	return services, nil
+}
+
 func (repo *ServicesRepository) CreateService(
 	ctx context.Context, service model.Service,
 ) error {
@@ -55,18 +140,14 @@ func (repo *ServicesRepository) FindService(
 	ctx context.Context, id uuid.UUID,
 ) (model.Service, error) {
 	row := repo.db.QueryRowContext(ctx,
-		`select id, type, state, permissions, currency, init_balance, balance
-        from services where id = $1`, id)
+		`select `+serviceColumns+` from services where id = $1`, id)
 
 	var service model.Service
-	if err := row.Scan(
-		&service.Id,
-		&service.Type,
-		&service.State,
-		&service.Permissions,
-		&service.Currency,
-		&service.InitBalance,
-		&service.Balance); err != nil {
+	if err := scanService(row, &service); err != nil {
+		return model.Service{}, err
+	}
+
+	if err := findHeld(ctx, repo.db, &service); err != nil {
 		return model.Service{}, err
 	}
 
@@ -76,11 +157,11 @@ func (repo *ServicesRepository) FindService(
 func (repo *ServicesRepository) FindAllServices(
 	ctx context.Context, cursor uuid.UUID,
 ) (iter.Seq2[model.Service, error], error) {
-    query := "select id, type, state, permissions, currency, init_balance, balance from services"
+    query := "select " + serviceColumns + " from services"
     params := make([]interface{}, 0, 1)
 
 	if (cursor != uuid.UUID{}) {
-		query += "where id > $1"
+		query += " where id > $1"
         params = append(params, cursor)
 	}
 
@@ -96,14 +177,10 @@ func (repo *ServicesRepository) FindAllServices(
 		defer rows.Close()
 		for rows.Next() {
 			var service model.Service
-			err := rows.Scan(
-				&service.Id,
-				&service.Type,
-				&service.State,
-				&service.Permissions,
-				&service.Currency,
-				&service.InitBalance,
-				&service.Balance)
+			err := scanService(rows, &service)
+			if err == nil {
+				err = findHeld(ctx, repo.db, &service)
+			}
 
 			if !yield(service, err) {
 				return
@@ -114,33 +191,87 @@ func (repo *ServicesRepository) FindAllServices(
 	return it, nil
 }
 
+// UpdateService moves a service to another state and records the change,
+// teller tells if the change is made by a teller. It returns the errors of
+// model.Service.ChangeState when the transition is not allowed.
 func (repo *ServicesRepository) UpdateService(
-	ctx context.Context, service model.Service,
+	ctx context.Context, change model.StateChange, teller bool,
 ) error {
-	result, err := repo.db.ExecContext(ctx,
-		"update services set state = $1 where id = $2", service.State, service.Id)
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	service, err := lockService(ctx, tx, change.Service)
 	if err != nil {
 		return err
 	}
-	if rows != 1 {
-		return fmt.Errorf("%d rows changed", rows)
+
+	if err := service.ChangeState(&change, teller); err != nil {
+		return err
 	}
-	return nil
+
+	// requests never moved money, anything that was active has to be settled
+	// before it is closed
+	if service.State == model.ServiceStateClosed &&
+		change.From != model.ServiceStateRequested {
+		var unpaid int
+		if err := tx.QueryRowContext(ctx, `select count(*) from loan_installments
+            where service_id = $1 and state = $2`,
+			service.Id,
+			model.InstallmentStatePending).Scan(&unpaid); err != nil {
+			return err
+		}
+
+		if err := service.CheckSettled(unpaid); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"update services set state = $1 where id = $2", service.State, service.Id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into service_state_changes(id, service_id, user_id, from_state, to_state, reason, time)
+        values ($1, $2, $3, $4, $5, $6, $7)`,
+		change.Id,
+		change.Service,
+		change.User,
+		change.From,
+		change.To,
+		change.Reason,
+		change.Time); err != nil {
+		return err
+	}
+
+	if service.State == model.ServiceStateActive {
+		if err := originateLoan(ctx, tx, service.Id); err != nil {
+			return err
+		}
+	}
+
+	if err := enqueueWebhookEvent(ctx, tx, model.WebhookEventServiceStateChanged,
+		map[string]string{
+			"id":    service.Id.String(),
+			"state": service.State,
+		},
+		`select user_id from user_service where service_id = $3`,
+		service.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (repo *ServicesRepository) FindUserServices(
 	ctx context.Context, user uuid.UUID,
 ) (iter.Seq2[model.Service, error], error) {
 	rows, err := repo.db.QueryContext(ctx,
-		`select s.id, s.type, s.state, s.permissions, s.currency, s.init_balance, s.balance
-        from services s
-        join user_service us on us.service_id = s.id
-        where us.user_id = $1
+		`select `+serviceColumns+` from services
+        where id in (select service_id from user_service where user_id = $1)
         order by id`, user)
 	if err != nil {
 		return nil, err
@@ -150,14 +281,10 @@ func (repo *ServicesRepository) FindUserServices(
 		defer rows.Close()
 		for rows.Next() {
 			var service model.Service
-			err := rows.Scan(
-				&service.Id,
-				&service.Type,
-				&service.State,
-				&service.Permissions,
-				&service.Currency,
-				&service.InitBalance,
-				&service.Balance)
+			err := scanService(rows, &service)
+			if err == nil {
+				err = findHeld(ctx, repo.db, &service)
+			}
 
 			if !yield(service, err) {
 				return
@@ -167,3 +294,97 @@ func (repo *ServicesRepository) FindUserServices(
 
 	return it, nil
 }
+
+// FindRequestedServices lists the services waiting to be activated by a
+// teller, oldest first.
+func (repo *ServicesRepository) FindRequestedServices(
+	ctx context.Context, cursor uuid.UUID,
+) (iter.Seq2[model.Service, error], error) {
+	query := "select " + serviceColumns + " from services"
+	params := make([]interface{}, 0, 2)
+
+	query += " where state = $1"
+	params = append(params, model.ServiceStateRequested)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Service, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var service model.Service
+			err := scanService(rows, &service)
+			if err == nil {
+				err = findHeld(ctx, repo.db, &service)
+			}
+
+			if !yield(service, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+const stateChangeColumns = `id, service_id, user_id, from_state, to_state, reason, time`
+
+func scanStateChange(row scanner, change *model.StateChange) error {
+	return row.Scan(
+		&change.Id,
+		&change.Service,
+		&change.User,
+		&change.From,
+		&change.To,
+		&change.Reason,
+		&change.Time)
+}
+
+func (repo *ServicesRepository) FindStateChanges(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.StateChange, error], error) {
+	query := "select " + stateChangeColumns + " from service_state_changes"
+	params := make([]interface{}, 0, 2)
+
+	query += " where service_id = $1"
+	params = append(params, serviceId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.StateChange, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var change model.StateChange
+			err := scanStateChange(rows, &change)
+
+			if !yield(change, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
@@ -6,21 +6,49 @@ import (
 	"errors"
 	"fmt"
 	"iter"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
 )
 
 type TransactionsRepository struct {
-	db       *sql.DB
-	jobQueue chan<- model.Transaction
+	db    *sql.DB
+	rules model.RiskRules
 }
 
-func NewTrsRepository(
-	db *sql.DB,
-	jobQueue chan<- model.Transaction,
-) TransactionsRepository {
-	repo := TransactionsRepository{db, jobQueue}
+var ErrQueueEmpty = errors.New("transaction queue is empty")
+
+const transactionColumns = `id, state, time, currency, amount, source, destination,
+    quote, rate, settled_amount, reverses, hold, batch, fee, charges, accept_penalty,
+    overdraft, failure_code, failure_reason`
+
+func scanTransaction(row scanner, transaction *model.Transaction) error {
+	return row.Scan(
+		&transaction.Id,
+		&transaction.State,
+		&transaction.Time,
+		&transaction.Currency,
+		&transaction.Amount,
+		&transaction.Source,
+		&transaction.Destination,
+		&transaction.Quote,
+		&transaction.Rate,
+		&transaction.SettledAmount,
+		&transaction.Reverses,
+		&transaction.Hold,
+		&transaction.Batch,
+		&transaction.Fee,
+		&transaction.Charges,
+		&transaction.AcceptPenalty,
+		&transaction.Overdraft,
+		&transaction.FailureCode,
+		&transaction.FailureReason)
+}
+
+func NewTrsRepository(db *sql.DB, rules model.RiskRules) TransactionsRepository {
+	repo := TransactionsRepository{db, rules}
 	return repo
 }
 
@@ -28,9 +56,71 @@ func (repo *TransactionsRepository) CreateTransaction(
 	ctx context.Context,
 	transaction model.Transaction,
 ) error {
-	row := repo.db.QueryRowContext(ctx, `insert
-        into transactions(id, state, time, currency, amount, source, destination)
-        values($1, $2, $3, $4, $5, $6, $7)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := enqueueTransaction(ctx, tx, &transaction); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// enqueueTransaction stores a new transaction and puts it in the queue, q
+// should be a database transaction so both happen atomically.
+func enqueueTransaction(ctx context.Context, q querier, transaction *model.Transaction) error {
+	if err := insertTransaction(ctx, q, transaction); err != nil {
+		return err
+	}
+
+	if _, err := q.ExecContext(ctx,
+		`insert into transaction_queue(transaction_id) values ($1)`,
+		transaction.Id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// settleTransaction stores a settled transaction and posts it to the ledger,
+// both services must have the currency of the transaction. Balances are not
+// checked, callers are expected to hold a lock on the services.
+func settleTransaction(ctx context.Context, tx *sql.Tx, transaction *model.Transaction) error {
+	if err := insertTransaction(ctx, tx, transaction); err != nil {
+		return err
+	}
+
+	entry, err := model.NewJournalEntry(transaction.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := entry.Debit(
+		transaction.Source, transaction.Currency, transaction.Amount); err != nil {
+		return err
+	}
+
+	if err := entry.Credit(
+		transaction.Destination, transaction.Currency, transaction.Amount); err != nil {
+		return err
+	}
+
+	if err := postJournalEntry(ctx, tx, &entry); err != nil {
+		return err
+	}
+
+	return notifyTransaction(ctx, tx, *transaction)
+}
+
+func insertTransaction(ctx context.Context, q querier, transaction *model.Transaction) error {
+	row := q.QueryRowContext(ctx, `insert
+        into transactions(id, state, time, currency, amount, source, destination, quote,
+            rate, settled_amount, reverses, hold, batch, fee, charges, accept_penalty, executed)
+        values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+            case when $2 = $17 then now() end)
         returning time`,
 		transaction.Id,
 		transaction.State,
@@ -38,193 +128,569 @@ func (repo *TransactionsRepository) CreateTransaction(
 		transaction.Currency,
 		transaction.Amount,
 		transaction.Source,
-		transaction.Destination)
+		transaction.Destination,
+		transaction.Quote,
+		transaction.Rate,
+		transaction.SettledAmount,
+		transaction.Reverses,
+		transaction.Hold,
+		transaction.Batch,
+		transaction.Fee,
+		transaction.Charges,
+		transaction.AcceptPenalty,
+		model.TransactionStateSuccess)
+
+	return row.Scan(&transaction.Time)
+}
 
-	if err := row.Scan(&transaction.Time); err != nil {
-		return err
+// RequeueTransactions puts every transaction still in processing state back in
+// the queue, this recovers transactions that were left behind by a crash.
+// Batch legs are requeued along with their batch instead.
+func (repo *TransactionsRepository) RequeueTransactions(ctx context.Context) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, `insert
+        into transaction_queue(transaction_id)
+        select id from transactions where state = $1 and batch is null
+        on conflict do nothing`,
+		model.TransactionStateProcessing)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ProcessTransaction claims the oldest queued transaction and executes it. The
+// queue entry stays locked until the database transaction ends, so a crash
+// while executing leaves it in the queue for another worker to pick up. It
+// returns ErrQueueEmpty when there is nothing to claim.
+func (repo *TransactionsRepository) ProcessTransaction(
+	ctx context.Context,
+) (model.Transaction, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Transaction{}, err
 	}
+	defer tx.Rollback()
 
-	select {
-	case repo.jobQueue <- transaction:
-		return nil
-	default:
-		return errors.New("queue is full or unavailable")
+	row := tx.QueryRowContext(ctx,
+		`select `+transactionColumns+` from transactions
+        where id = (
+            select transaction_id from transaction_queue
+            where available <= now()
+            order by enqueued
+            limit 1
+            for update skip locked)`)
+
+	var transaction model.Transaction
+	if err := scanTransaction(row, &transaction); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Transaction{}, ErrQueueEmpty
+		}
+		return model.Transaction{}, err
 	}
+
+	if _, err := tx.ExecContext(ctx, "savepoint execute"); err != nil {
+		return transaction, err
+	}
+
+	decision, execErr := assessRisk(ctx, tx, repo.rules, transaction)
+	if execErr == nil && decision.Action == model.RiskActionReview {
+		if err := parkTransaction(ctx, tx, &transaction, decision); err != nil {
+			return transaction, err
+		}
+	} else if execErr == nil {
+		execErr = executeTransaction(ctx, tx, transaction)
+	}
+
+	if execErr != nil {
+		if _, err := tx.ExecContext(ctx, "rollback to savepoint execute"); err != nil {
+			return transaction, err
+		}
+
+		if isTransient(execErr) {
+			retry, err := retryQueueEntry(ctx, tx,
+				"transaction_queue", "transaction_id", transaction.Id)
+			if err != nil {
+				return transaction, err
+			}
+
+			if retry {
+				if err := tx.Commit(); err != nil {
+					return transaction, err
+				}
+				return transaction, fmt.Errorf("%w: %w", ErrExecutionRetry, execErr)
+			}
+		}
+
+		transaction.Fail(transactionFailure(execErr))
+		if _, err := tx.ExecContext(ctx,
+			`update transactions set state = $1, failure_code = $2, failure_reason = $3
+            where id = $4`,
+			transaction.State,
+			transaction.FailureCode,
+			transaction.FailureReason,
+			transaction.Id); err != nil {
+			return transaction, err
+		}
+
+		if err := chargeInsufficientFunds(ctx, tx, transaction, execErr); err != nil {
+			return transaction, err
+		}
+	} else if transaction.State != model.TransactionStateReview {
+		transaction.State = model.TransactionStateSuccess
+	}
+
+	if err := notifyTransaction(ctx, tx, transaction); err != nil {
+		return transaction, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`delete from transaction_queue where transaction_id = $1`,
+		transaction.Id); err != nil {
+		return transaction, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return transaction, err
+	}
+
+	return transaction, execErr
 }
 
-func (repo *TransactionsRepository) ExecuteTransaction(
+func executeTransaction(
+	ctx context.Context,
+	tx *sql.Tx,
 	transaction model.Transaction,
 ) error {
 	if transaction.Source == transaction.Destination {
-		return fmt.Errorf("transaction: %s invalid, src and dst are the same", transaction.Id)
+		return model.NewTransactionError(model.TransactionFailureInvalid,
+			"transaction %s invalid, src and dst are the same", transaction.Id)
 	}
 
-	tx, err := repo.db.BeginTx(context.Background(), nil)
+	services, err := lockServices(ctx, tx, transaction.Source, transaction.Destination)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	srcRow := tx.QueryRow(`select id, type, state, permissions, currency, init_balance, balance
-        from services
-        where id = $1
-        for no key update`,
-		transaction.Source)
-
-	var srcService model.Service
-	if err := srcRow.Scan(
-		&srcService.Id,
-		&srcService.Type,
-		&srcService.State,
-        &srcService.Permissions,
-		&srcService.Currency,
-		&srcService.InitBalance,
-		&srcService.Balance,
-	); err != nil {
-		return err
+	srcService, ok := services[transaction.Source]
+	if !ok {
+		return model.NewTransactionError(model.TransactionFailureServiceNotFound,
+			"source %s does not exist", transaction.Source)
 	}
 
 	if srcService.State != model.ServiceStateActive {
-		return errors.New("source is not active")
+		return model.NewTransactionError(model.TransactionFailureSourceInactive,
+			"source %s is not active", srcService.Id)
+	}
+
+	// a capture is counted as held until it executes
+	if transaction.Hold.Valid {
+		srcService.Held = srcService.Held.Sub(transaction.Amount)
+	}
+
+	if transaction.Currency != srcService.Currency {
+		return model.NewTransactionError(model.TransactionFailureCurrencyMismatch,
+			"transaction currency %s does not match source currency %s",
+			transaction.Currency, srcService.Currency)
 	}
 
-	if err := srcService.Debit(transaction.Amount); err != nil {
+	dstService, ok := services[transaction.Destination]
+	if !ok {
+		return model.NewTransactionError(model.TransactionFailureServiceNotFound,
+			"destination %s does not exist", transaction.Destination)
+	}
+
+	if dstService.State != model.ServiceStateActive {
+		return model.NewTransactionError(model.TransactionFailureDestinationInactive,
+			"destination %s is not active", dstService.Id)
+	}
+
+	transaction.Rate = decimal.NewFromInt(1)
+	if srcService.Currency != dstService.Currency {
+		if transaction.Quote.Valid {
+			transaction.Rate, err = findQuotedRate(ctx, tx, transaction.Quote.UUID,
+				transaction.Id, srcService.Currency, dstService.Currency)
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.NewTransactionError(model.TransactionFailureQuoteInvalid,
+					"quote %s is expired or does not match the transaction",
+					transaction.Quote.UUID)
+			}
+		} else {
+			transaction.Rate, err = findRate(ctx, tx,
+				srcService.Currency, dstService.Currency)
+			if errors.Is(err, ErrRateUnavailable) {
+				return model.NewTransactionError(model.TransactionFailureRateUnavailable,
+					"no exchange rate from %s to %s",
+					srcService.Currency, dstService.Currency)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	transaction.SettledAmount = model.Convert(transaction.Amount, transaction.Rate)
+	if !transaction.SettledAmount.IsPositive() {
+		return model.NewTransactionError(model.TransactionFailureInvalid,
+			"transaction %s converts to nothing in %s", transaction.Id, dstService.Currency)
+	}
+
+	if err := checkLimits(ctx, tx, transaction); err != nil {
 		return err
 	}
 
-	dstRow := tx.QueryRow(`select id, type, state, permissions, currency, init_balance, balance
-        from services
-        where id = $1
-        for no key update`,
-		transaction.Destination)
-
-	var dstService model.Service
-	if err := dstRow.Scan(
-		&dstService.Id,
-		&dstService.Type,
-		&dstService.State,
-		&dstService.Permissions,
-		&dstService.Currency,
-		&dstService.InitBalance,
-		&dstService.Balance,
-	); err != nil {
+	// the transfer and early withdrawal fees are charged on top of the amount,
+	// the source has to be able to cover all of them
+	var transferFee, penaltyFee, overdraftFee, nsfFee decimal.Decimal
+	if transaction.Chargeable() {
+		transferFee, err = findFee(ctx, tx, srcService, model.FeeKindTransfer, transaction.Amount)
+		if err != nil {
+			return err
+		}
+
+		penaltyFee, err = findEarlyWithdrawalFee(ctx, tx, srcService, transaction)
+		if err != nil {
+			return err
+		}
+	}
+
+	// services that pay NSF items can go over their overdraft limit, fees and
+	// reversals are never paid that way
+	available := srcService.Available()
+	debit := srcService.Debit
+	if transaction.Chargeable() {
+		debit = srcService.Overdraw
+	}
+	if err := debit(transaction.Amount.Add(transferFee).Add(penaltyFee)); err != nil {
 		return err
 	}
+	transaction.Overdraft = srcService.OverdraftState()
 
-	if dstService.State != model.ServiceStateActive {
-		return errors.New("destination is not active")
+	if transaction.Chargeable() &&
+		!available.IsNegative() && srcService.Available().IsNegative() {
+		overdraftFee, err = findFee(ctx, tx, srcService, model.FeeKindOverdraft, transaction.Amount)
+		if err != nil {
+			return err
+		}
 	}
 
-	if err := dstService.Credit(transaction.Amount); err != nil {
+	if transaction.Chargeable() && transaction.Overdraft == model.TransactionOverdraftNSFPaid {
+		nsfFee, err = findFee(ctx, tx, srcService,
+			model.FeeKindInsufficientFunds, transaction.Amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := dstService.Credit(transaction.SettledAmount); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(
-		`update services set balance = $1 where id = $2`,
-		srcService.Balance,
-		srcService.Id); err != nil {
+	entry, err := model.NewJournalEntry(transaction.Id)
+	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(
-		`update services set balance = $1 where id = $2`,
-		dstService.Balance,
-		dstService.Id); err != nil {
+	if err := entry.Debit(srcService.Id, srcService.Currency, transaction.Amount); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(
-		`update transactions set state = $1 where id = $2`,
+	if srcService.Currency != dstService.Currency {
+		if err := postExchange(ctx, tx, &entry,
+			srcService.Currency, transaction.Amount,
+			dstService.Currency, transaction.SettledAmount); err != nil {
+			return err
+		}
+	}
+
+	if err := entry.Credit(
+		dstService.Id, dstService.Currency, transaction.SettledAmount); err != nil {
+		return err
+	}
+
+	if err := postJournalEntry(ctx, tx, &entry); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `update transactions
+        set state = $1, rate = $2, settled_amount = $3, overdraft = $4, executed = now()
+        where id = $5`,
 		model.TransactionStateSuccess,
+		transaction.Rate,
+		transaction.SettledAmount,
+		transaction.Overdraft,
 		transaction.Id); err != nil {
 		return err
 	}
 
-	tx.Commit()
+	// a hold is only used up once its capture goes through, a capture that
+	// fails leaves the hold active
+	if transaction.Hold.Valid {
+		if _, err := tx.ExecContext(ctx,
+			`update holds set state = $1, captured = $2 where id = $3`,
+			model.HoldStateCaptured,
+			transaction.Amount,
+			transaction.Hold.UUID); err != nil {
+			return err
+		}
+	}
+
+	if dstService.Type == model.ServiceTypeLoan {
+		if err := repayLoan(ctx, tx, dstService, transaction); err != nil {
+			return err
+		}
+	}
+
+	charges := uuid.NullUUID{UUID: transaction.Id, Valid: true}
+	if transferFee.IsPositive() {
+		if _, err := chargeFee(ctx, tx, srcService,
+			model.FeeKindTransfer, transferFee, charges); err != nil {
+			return err
+		}
+	}
+
+	if penaltyFee.IsPositive() {
+		if _, err := chargeFee(ctx, tx, srcService,
+			model.FeeKindEarlyWithdrawal, penaltyFee, charges); err != nil {
+			return err
+		}
+	}
+
+	if overdraftFee.IsPositive() {
+		if _, err := chargeFee(ctx, tx, srcService,
+			model.FeeKindOverdraft, overdraftFee, charges); err != nil {
+			return err
+		}
+	}
+
+	if nsfFee.IsPositive() {
+		if _, err := chargeFee(ctx, tx, srcService,
+			model.FeeKindInsufficientFunds, nsfFee, charges); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// postExchange moves the money across currencies through the foreign exchange
+// position accounts of the bank, keeping the entry balanced in both.
+func postExchange(
+	ctx context.Context,
+	q querier,
+	entry *model.JournalEntry,
+	srcCurrency string,
+	srcAmount decimal.Decimal,
+	dstCurrency string,
+	dstAmount decimal.Decimal,
+) error {
+	srcPosition, err := findBankAccount(ctx, q, model.BankAccountFxPosition, srcCurrency)
+	if err != nil {
+		return err
+	}
+
+	dstPosition, err := findBankAccount(ctx, q, model.BankAccountFxPosition, dstCurrency)
+	if err != nil {
+		return err
+	}
+
+	if err := entry.Credit(srcPosition, srcCurrency, srcAmount); err != nil {
+		return err
+	}
+
+	return entry.Debit(dstPosition, dstCurrency, dstAmount)
+}
+
+// transactionFailure converts an execution error into the reason stored on the
+// transaction, errors that don't come from the domain are not exposed.
+func transactionFailure(err error) *model.TransactionError {
+	var trsErr *model.TransactionError
+	if errors.As(err, &trsErr) {
+		return trsErr
+	}
+
+	return model.NewTransactionError(model.TransactionFailureInternal,
+		"transaction could not be processed")
+}
+
 func (repo *TransactionsRepository) FindTransaction(
 	ctx context.Context,
 	id uuid.UUID,
 ) (model.Transaction, error) {
 	row := repo.db.QueryRowContext(ctx,
-		`select id, state, time, currency, amount, source, destination
-        from transactions where id = $1`, id)
+		`select `+transactionColumns+` from transactions where id = $1`, id)
 
 	var transaction model.Transaction
-	if err := row.Scan(
-		&transaction.Id,
-		&transaction.State,
-		&transaction.Time,
-		&transaction.Currency,
-		&transaction.Amount,
-		&transaction.Source,
-		&transaction.Destination); err != nil {
+	if err := scanTransaction(row, &transaction); err != nil {
+		return model.Transaction{}, err
+	}
+
+	rows, err := repo.db.QueryContext(ctx,
+		`select id, fee from transactions where reverses = $1 or charges = $1 order by id`, id)
+	if err != nil {
 		return model.Transaction{}, err
 	}
+	defer rows.Close()
 
-	return transaction, nil
+	for rows.Next() {
+		var related uuid.UUID
+		var fee string
+		if err := rows.Scan(&related, &fee); err != nil {
+			return model.Transaction{}, err
+		}
+
+		if fee != "" {
+			transaction.Fees = append(transaction.Fees, related)
+		} else {
+			transaction.Reversals = append(transaction.Reversals, related)
+		}
+	}
+
+	return transaction, rows.Err()
 }
 
-func (repo *TransactionsRepository) FindAllTransactions(
+// CreateReversal queues a transaction that sends amount back to the source of
+// the original, a zero amount reverses everything that is left. The original
+// is locked so concurrent reversals can't exceed its amount.
+func (repo *TransactionsRepository) CreateReversal(
 	ctx context.Context,
-	cursor uuid.UUID,
-) (iter.Seq2[model.Transaction, error], error) {
-	query := "select id, state, time, currency, amount, source, destination from transactions"
-	params := make([]interface{}, 0, 1)
+	originalId uuid.UUID,
+	amount decimal.Decimal,
+) (model.Transaction, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	defer tx.Rollback()
 
-	if (cursor != uuid.UUID{}) {
-		query += " where id > $1"
-		params = append(params, cursor)
+	row := tx.QueryRowContext(ctx,
+		`select `+transactionColumns+` from transactions where id = $1 for update`,
+		originalId)
+
+	var original model.Transaction
+	if err := scanTransaction(row, &original); err != nil {
+		return model.Transaction{}, err
 	}
 
-	query += " order by id"
-	query += " limit 10"
+	row = tx.QueryRowContext(ctx,
+		`select coalesce(sum(amount), 0) from transactions
+        where reverses = $1 and state <> $2`,
+		original.Id,
+		model.TransactionStateError)
 
-	rows, err := repo.db.QueryContext(ctx, query, params...)
+	var reversed decimal.Decimal
+	if err := row.Scan(&reversed); err != nil {
+		return model.Transaction{}, err
+	}
+
+	row = tx.QueryRowContext(ctx,
+		`select currency from services where id = $1`, original.Destination)
+
+	var currency string
+	if err := row.Scan(&currency); err != nil {
+		return model.Transaction{}, err
+	}
+
+	if amount.IsZero() {
+		amount = original.SettledAmount.Sub(reversed)
+	}
+
+	reversal, err := model.NewReversal(original, currency, amount, reversed)
 	if err != nil {
-		return nil, err
+		return model.Transaction{}, err
 	}
 
-	it := func(yield func(model.Transaction, error) bool) {
-		defer rows.Close()
+	if err := enqueueTransaction(ctx, tx, &reversal); err != nil {
+		return model.Transaction{}, err
+	}
 
-		var transaction model.Transaction
-		for rows.Next() {
-			err := rows.Scan(
-				&transaction.Id,
-				&transaction.State,
-				&transaction.Time,
-				&transaction.Currency,
-				&transaction.Amount,
-				&transaction.Source,
-				&transaction.Destination)
+	if err := tx.Commit(); err != nil {
+		return model.Transaction{}, err
+	}
 
-			if !yield(transaction, err) {
-				return
-			}
+	return reversal, nil
+}
+
+// transactionConditions turns a filter into where conditions, the values are
+// appended to params and referenced by position. service is the service being
+// listed, if any. The direction is ignored without a service, callers reject
+// it before.
+func transactionConditions(
+	filter model.TransactionFilter,
+	service uuid.NullUUID,
+	params *[]any,
+) []string {
+	param := func(value any) string {
+		*params = append(*params, value)
+		return fmt.Sprintf("$%d", len(*params))
+	}
+
+	conditions := make([]string, 0)
+	if service.Valid {
+		s := param(service.UUID)
+		switch filter.Direction {
+		case model.TransactionDirectionIn:
+			conditions = append(conditions, "destination = "+s)
+		case model.TransactionDirectionOut:
+			conditions = append(conditions, "source = "+s)
+		default:
+			conditions = append(conditions, "(source = "+s+" or destination = "+s+")")
 		}
 	}
 
-	return it, nil
+	if filter.Counterparty.Valid {
+		c := param(filter.Counterparty.UUID)
+		switch {
+		case service.Valid && filter.Direction == model.TransactionDirectionIn:
+			conditions = append(conditions, "source = "+c)
+		case service.Valid && filter.Direction == model.TransactionDirectionOut:
+			conditions = append(conditions, "destination = "+c)
+		default:
+			conditions = append(conditions, "(source = "+c+" or destination = "+c+")")
+		}
+	}
+
+	if filter.State != "" {
+		conditions = append(conditions, "state = "+param(filter.State))
+	}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "time >= "+param(filter.From))
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "time < "+param(filter.To))
+	}
+
+	if filter.MinAmount.Valid {
+		conditions = append(conditions, "amount >= "+param(filter.MinAmount.Decimal))
+	}
+
+	if filter.MaxAmount.Valid {
+		conditions = append(conditions, "amount <= "+param(filter.MaxAmount.Decimal))
+	}
+
+	return conditions
 }
 
-func (repo *TransactionsRepository) FindServiceTransactions(
+// findTransactions lists the transactions that match the conditions, 10 at a
+// time in id order.
+func (repo *TransactionsRepository) findTransactions(
 	ctx context.Context,
-	serviceId uuid.UUID,
+	filter model.TransactionFilter,
+	service uuid.NullUUID,
 	cursor uuid.UUID,
 ) (iter.Seq2[model.Transaction, error], error) {
-	query := "select id, state, time, currency, amount, source, destination from transactions"
-	params := make([]interface{}, 0, 2)
-
-	query += " where source = $1"
-	params = append(params, serviceId)
+	query := "select " + transactionColumns + " from transactions"
+	params := make([]any, 0, 9)
 
+	conditions := transactionConditions(filter, service, &params)
 	if (cursor != uuid.UUID{}) {
-		query += " and id > $2"
 		params = append(params, cursor)
+		conditions = append(conditions, fmt.Sprintf("id > $%d", len(params)))
+	}
+
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
 	}
 
 	query += " order by id"
@@ -239,22 +705,33 @@ func (repo *TransactionsRepository) FindServiceTransactions(
 		defer rows.Close()
 		for rows.Next() {
 			var transaction model.Transaction
-			err := rows.Scan(
-				&transaction.Id,
-				&transaction.State,
-				&transaction.Time,
-				&transaction.Currency,
-				&transaction.Amount,
-				&transaction.Source,
-				&transaction.Destination)
+			err := scanTransaction(rows, &transaction)
 
 			if !yield(transaction, err) {
 				return
 			}
 		}
-
-		rows.Close()
 	}
 
 	return it, nil
 }
+
+func (repo *TransactionsRepository) FindAllTransactions(
+	ctx context.Context,
+	filter model.TransactionFilter,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Transaction, error], error) {
+	return repo.findTransactions(ctx, filter, uuid.NullUUID{}, cursor)
+}
+
+// FindServiceTransactions lists the transactions of a service, both outgoing
+// and incoming unless the filter says otherwise.
+func (repo *TransactionsRepository) FindServiceTransactions(
+	ctx context.Context,
+	serviceId uuid.UUID,
+	filter model.TransactionFilter,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Transaction, error], error) {
+	return repo.findTransactions(ctx, filter,
+		uuid.NullUUID{UUID: serviceId, Valid: true}, cursor)
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"iter"
 
 	"github.com/google/uuid"
@@ -91,14 +92,36 @@ func (repo *UsersRepository) FindAllUsers(
 }
 
 func (repo *UsersRepository) UpdateUser(ctx context.Context, user model.User) error {
-	if _, err := repo.db.ExecContext(ctx,
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx,
 		`update users set
         username = coalesce(nullif($1, ''), username),
         fullname = coalesce(nullif($2, ''), fullname),
         password = coalesce(nullif($3, ''), password)
-        where id = $4`, user.Username, user.Fullname, user.Passhash, user.Id); err != nil {
+        where id = $4
+        returning username, fullname`, user.Username, user.Fullname, user.Passhash, user.Id)
+	if err := row.Scan(&user.Username, &user.Fullname); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
 		return err
 	}
 
-	return nil
+	if err := enqueueWebhookEvent(ctx, tx, model.WebhookEventUserUpdated,
+		map[string]string{
+			"id":       user.Id.String(),
+			"username": user.Username,
+			"fullname": user.Fullname,
+		},
+		`select $3::uuid`,
+		user.Id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
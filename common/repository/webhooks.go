@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type WebhooksRepository struct {
+	db *sql.DB
+}
+
+func NewWhkRepository(db *sql.DB) WebhooksRepository {
+	return WebhooksRepository{db}
+}
+
+const webhookColumns = `id, user_id, url, secret,
+    (select string_agg(event, ',' order by event) from webhook_events
+        where webhook_id = webhooks.id),
+    time`
+
+func scanWebhook(row scanner, webhook *model.Webhook) error {
+	var events string
+	if err := row.Scan(
+		&webhook.Id,
+		&webhook.User,
+		&webhook.Url,
+		&webhook.Secret,
+		&events,
+		&webhook.Time); err != nil {
+		return err
+	}
+
+	webhook.Events = strings.Split(events, ",")
+	return nil
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, state, attempts, next_attempt,
+    last_status, last_error, time`
+
+func scanDelivery(row scanner, delivery *model.Delivery) error {
+	return row.Scan(
+		&delivery.Id,
+		&delivery.Webhook,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.State,
+		&delivery.Attempts,
+		&delivery.NextAttempt,
+		&delivery.LastStatus,
+		&delivery.LastError,
+		&delivery.Time)
+}
+
+func (repo *WebhooksRepository) CreateWebhook(ctx context.Context, webhook model.Webhook) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `insert
+        into webhooks(id, user_id, url, secret, time)
+        values ($1, $2, $3, $4, $5)`,
+		webhook.Id,
+		webhook.User,
+		webhook.Url,
+		webhook.Secret,
+		webhook.Time); err != nil {
+		return err
+	}
+
+	for _, event := range webhook.Events {
+		if _, err := tx.ExecContext(ctx, `insert
+            into webhook_events(webhook_id, event)
+            values ($1, $2)
+            on conflict do nothing`,
+			webhook.Id,
+			event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (repo *WebhooksRepository) FindUserWebhooks(
+	ctx context.Context,
+	userId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Webhook, error], error) {
+	query := "select " + webhookColumns + " from webhooks"
+	params := make([]interface{}, 0, 2)
+
+	query += " where user_id = $1"
+	params = append(params, userId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Webhook, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var webhook model.Webhook
+			err := scanWebhook(rows, &webhook)
+
+			if !yield(webhook, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+func (repo *WebhooksRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	result, err := repo.db.ExecContext(ctx, `delete from webhooks where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (repo *WebhooksRepository) FindWebhookDeliveries(
+	ctx context.Context,
+	webhookId uuid.UUID,
+	cursor uuid.UUID,
+) (iter.Seq2[model.Delivery, error], error) {
+	query := "select " + deliveryColumns + " from webhook_deliveries"
+	params := make([]interface{}, 0, 2)
+
+	query += " where webhook_id = $1"
+	params = append(params, webhookId)
+
+	if (cursor != uuid.UUID{}) {
+		query += " and id > $2"
+		params = append(params, cursor)
+	}
+
+	query += " order by id"
+	query += " limit 10"
+
+	rows, err := repo.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	it := func(yield func(model.Delivery, error) bool) {
+		defer rows.Close()
+		for rows.Next() {
+			var delivery model.Delivery
+			err := scanDelivery(rows, &delivery)
+
+			if !yield(delivery, err) {
+				return
+			}
+		}
+	}
+
+	return it, nil
+}
+
+func updateDelivery(ctx context.Context, q querier, delivery model.Delivery) error {
+	_, err := q.ExecContext(ctx, `update webhook_deliveries
+        set state = $1, attempts = $2, next_attempt = $3, last_status = $4, last_error = $5
+        where id = $6`,
+		delivery.State,
+		delivery.Attempts,
+		delivery.NextAttempt,
+		delivery.LastStatus,
+		delivery.LastError,
+		delivery.Id)
+	return err
+}
+
+// Redeliver sends a delivery again regardless of its previous outcome.
+func (repo *WebhooksRepository) Redeliver(
+	ctx context.Context,
+	webhookId uuid.UUID,
+	deliveryId uuid.UUID,
+) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+deliveryColumns+` from webhook_deliveries
+        where id = $1 and webhook_id = $2
+        for update`,
+		deliveryId,
+		webhookId)
+
+	var delivery model.Delivery
+	if err := scanDelivery(row, &delivery); err != nil {
+		return err
+	}
+
+	delivery.Redeliver()
+	if err := updateDelivery(ctx, tx, delivery); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeliverNext claims the oldest delivery that is due and hands it to send.
+// Claiming pushes the next attempt forward by DeliveryClaimTimeout so other
+// replicas skip it, the delivery is sent outside of any database transaction
+// and its outcome is recorded afterwards. It returns ErrQueueEmpty when
+// nothing is due.
+func (repo *WebhooksRepository) DeliverNext(
+	ctx context.Context,
+	send func(model.Webhook, model.Delivery) (int, error),
+) (model.Delivery, error) {
+	delivery, webhook, err := repo.claimNextDelivery(ctx)
+	if err != nil {
+		return delivery, err
+	}
+
+	delivery.Record(send(webhook, delivery))
+	if err := updateDelivery(ctx, repo.db, delivery); err != nil {
+		return delivery, err
+	}
+
+	return delivery, nil
+}
+
+func (repo *WebhooksRepository) claimNextDelivery(
+	ctx context.Context,
+) (model.Delivery, model.Webhook, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Delivery{}, model.Webhook{}, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `select `+deliveryColumns+` from webhook_deliveries
+        where state = $1 and next_attempt <= now()
+        order by next_attempt
+        limit 1
+        for update skip locked`,
+		model.DeliveryStatePending)
+
+	var delivery model.Delivery
+	if err := scanDelivery(row, &delivery); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Delivery{}, model.Webhook{}, ErrQueueEmpty
+		}
+		return model.Delivery{}, model.Webhook{}, err
+	}
+
+	row = tx.QueryRowContext(ctx,
+		`select `+webhookColumns+` from webhooks where id = $1`, delivery.Webhook)
+
+	var webhook model.Webhook
+	if err := scanWebhook(row, &webhook); err != nil {
+		return delivery, model.Webhook{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`update webhook_deliveries set next_attempt = $1 where id = $2`,
+		time.Now().Add(model.DeliveryClaimTimeout),
+		delivery.Id); err != nil {
+		return delivery, webhook, err
+	}
+
+	return delivery, webhook, tx.Commit()
+}
+
+// enqueueWebhookEvent creates a delivery for every webhook subscribed to the
+// event that belongs to a teller or to one of the users selected by owners, a
+// query that may use the parameters from $3 on. q should be the database
+// transaction that makes the change so the event is only sent if it commits.
+func enqueueWebhookEvent(
+	ctx context.Context,
+	q querier,
+	event string,
+	data map[string]string,
+	owners string,
+	args ...any,
+) error {
+	rows, err := q.QueryContext(ctx, `select w.id from webhooks w
+        join webhook_events e on e.webhook_id = w.id
+        join users u on u.id = w.user_id
+        where e.event = $1
+        and (u.clearance >= $2 or w.user_id in (`+owners+`))`,
+		append([]any{event, model.UserClearanceTeller}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	webhooks := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var webhook uuid.UUID
+		if err := rows.Scan(&webhook); err != nil {
+			rows.Close()
+			return err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		delivery, err := model.NewDelivery(webhook, event, data)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.ExecContext(ctx, `insert
+            into webhook_deliveries(id, webhook_id, event, payload, state, next_attempt, time)
+            values ($1, $2, $3, $4, $5, $6, $7)`,
+			delivery.Id,
+			delivery.Webhook,
+			delivery.Event,
+			delivery.Payload,
+			delivery.State,
+			delivery.NextAttempt,
+			delivery.Time); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueueTransactionEvent sends the outcome of a transaction to the owners of
+// both of its services. Failures only go to the owners of the source, the
+// payee never sees why a payment to them failed.
+func enqueueTransactionEvent(ctx context.Context, q querier, transaction model.Transaction) error {
+	var event string
+	switch transaction.State {
+	case model.TransactionStateSuccess:
+		event = model.WebhookEventTransactionSucceeded
+	case model.TransactionStateError:
+		return enqueueWebhookEvent(ctx, q, model.WebhookEventTransactionFailed,
+			model.TransactionEventData(transaction),
+			`select user_id from user_service where service_id = $3`,
+			transaction.Source)
+	default:
+		return nil
+	}
+
+	return enqueueWebhookEvent(ctx, q, event, model.TransactionEventData(transaction),
+		`select user_id from user_service where service_id = $3 or service_id = $4`,
+		transaction.Source,
+		transaction.Destination)
+}
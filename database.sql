@@ -22,7 +22,8 @@ DROP TYPE IF EXISTS SERVICE_TYPE CASCADE;
 -- LOA Loan
 -- LOC Line of credit
 -- COD Certificate of deposit
-CREATE TYPE SERVICE_TYPE AS ENUM ('SAV', 'CHQ', 'LOA', 'LOC', 'COD');
+-- BNK Internal account of the bank
+CREATE TYPE SERVICE_TYPE AS ENUM ('SAV', 'CHQ', 'LOA', 'LOC', 'COD', 'BNK');
 
 DROP TYPE IF EXISTS SERVICE_STATE CASCADE;
 -- REQ Requested service
@@ -42,26 +43,564 @@ CREATE TABLE services (
     balance NUMERIC(20, 2),
     PRIMARY KEY (id)
 );
+CREATE INDEX services_requested_idx ON services (id) WHERE state = 'REQ';
+
+-- limits on the outgoing transactions of a service, 0 means no limit
+DROP TABLE IF EXISTS service_limits CASCADE;
+CREATE TABLE service_limits (
+    service_id UUID,
+    max_amount NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    daily_amount NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    monthly_amount NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    daily_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (service_id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+
+-- from_state is the state the service was in before the change
+DROP TABLE IF EXISTS service_state_changes CASCADE;
+CREATE TABLE service_state_changes (
+    id UUID,
+    service_id UUID NOT NULL,
+    user_id UUID,
+    from_state SERVICE_STATE NOT NULL,
+    to_state SERVICE_STATE NOT NULL,
+    reason VARCHAR(300) NOT NULL DEFAULT '',
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users ON DELETE SET NULL
+);
+CREATE INDEX service_state_changes_service_idx ON service_state_changes (service_id, id);
+
+-- user_id is the teller that made the change, it is null for changes the bank
+-- made on its own
+DROP TABLE IF EXISTS service_permission_changes CASCADE;
+CREATE TABLE service_permission_changes (
+    id UUID,
+    service_id UUID NOT NULL,
+    user_id UUID,
+    permission VARCHAR(20) NOT NULL,
+    granted BOOLEAN NOT NULL,
+    reason VARCHAR(300) NOT NULL,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users ON DELETE SET NULL
+);
+CREATE INDEX service_permission_changes_service_idx ON service_permission_changes (service_id, id);
+
+DROP TYPE IF EXISTS NSF_POLICY CASCADE;
+-- REJ Reject debits over the overdraft limit
+-- PAY Pay debits over the overdraft limit and charge the NSF fee
+CREATE TYPE NSF_POLICY AS ENUM ('REJ', 'PAY');
+
+-- overdraft_limit is how far below zero the service can go, rate is the
+-- yearly percentage the overdrawn balance pays and accrued the interest of
+-- every day up to accrued_through that was not charged yet
+DROP TABLE IF EXISTS overdrafts CASCADE;
+CREATE TABLE overdrafts (
+    service_id UUID,
+    overdraft_limit NUMERIC(20, 2) NOT NULL,
+    nsf_policy NSF_POLICY NOT NULL,
+    rate NUMERIC(7, 4) NOT NULL,
+    accrued NUMERIC(30, 10) NOT NULL DEFAULT 0,
+    accrued_through DATE NOT NULL,
+    PRIMARY KEY (service_id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+CREATE INDEX overdrafts_accrued_idx ON overdrafts (accrued_through);
+
+-- rate is the yearly percentage charged on carried balances, the minimum
+-- payment is minimum_rate percent of the statement but at least
+-- minimum_amount, statements are due grace_days after their cycle ends
+DROP TABLE IF EXISTS credit_lines CASCADE;
+CREATE TABLE credit_lines (
+    service_id UUID,
+    credit_limit NUMERIC(20, 2) NOT NULL,
+    rate NUMERIC(7, 4) NOT NULL,
+    minimum_rate NUMERIC(7, 4) NOT NULL,
+    minimum_amount NUMERIC(20, 2) NOT NULL,
+    grace_days SMALLINT NOT NULL,
+    PRIMARY KEY (service_id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+
+DROP TYPE IF EXISTS BILLING_CYCLE_STATE CASCADE;
+-- OPN Open
+-- CLS Closed, the statement was issued
+CREATE TYPE BILLING_CYCLE_STATE AS ENUM ('OPN', 'CLS');
+
+-- owed amounts are positive, assessed is set once the payments received
+-- before the due date were checked against the minimum payment
+DROP TABLE IF EXISTS billing_cycles CASCADE;
+CREATE TABLE billing_cycles (
+    id UUID,
+    service_id UUID NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    due_date DATE,
+    state BILLING_CYCLE_STATE NOT NULL,
+    statement NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    minimum_due NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    interest NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    payments NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    late_fee NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    late BOOLEAN NOT NULL DEFAULT FALSE,
+    assessed BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (id),
+    FOREIGN KEY (service_id) REFERENCES credit_lines ON DELETE CASCADE
+);
+CREATE INDEX billing_cycles_service_idx ON billing_cycles (service_id, id);
+CREATE UNIQUE INDEX billing_cycles_open_idx ON billing_cycles (service_id)
+    WHERE state = 'OPN';
+CREATE INDEX billing_cycles_end_idx ON billing_cycles (end_date) WHERE state = 'OPN';
+CREATE INDEX billing_cycles_due_idx ON billing_cycles (due_date)
+    WHERE state = 'CLS' AND NOT assessed;
+
+DROP TYPE IF EXISTS DAY_COUNT CASCADE;
+-- ACT/365 Actual days over a 365 day year
+-- ACT/360 Actual days over a 360 day year
+-- ACT/ACT Actual days over the days of the year
+-- 30/360  30 day months over a 360 day year
+CREATE TYPE DAY_COUNT AS ENUM ('ACT/365', 'ACT/360', 'ACT/ACT', '30/360');
+
+-- rate is a yearly percentage, accrued is the interest of every day up to
+-- accrued_through that was not paid yet
+DROP TABLE IF EXISTS service_interest CASCADE;
+CREATE TABLE service_interest (
+    service_id UUID,
+    rate NUMERIC(7, 4) NOT NULL,
+    day_count DAY_COUNT NOT NULL,
+    accrued NUMERIC(30, 10) NOT NULL DEFAULT 0,
+    accrued_through DATE NOT NULL,
+    PRIMARY KEY (service_id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+CREATE INDEX service_interest_accrued_idx ON service_interest (accrued_through);
+
+DROP TYPE IF EXISTS DEPOSIT_INSTRUCTION CASCADE;
+-- ROL Roll over into a new term of the same length
+-- PAY Pay out the balance to payout_service_id
+CREATE TYPE DEPOSIT_INSTRUCTION AS ENUM ('ROL', 'PAY');
+
+DROP TYPE IF EXISTS DEPOSIT_STATE CASCADE;
+-- OPN Open, the term is running
+-- MAT Matured
+CREATE TYPE DEPOSIT_STATE AS ENUM ('OPN', 'MAT');
+
+-- term is the number of months from start_date to maturity_date
+DROP TABLE IF EXISTS deposits CASCADE;
+CREATE TABLE deposits (
+    service_id UUID,
+    term SMALLINT NOT NULL,
+    start_date DATE NOT NULL,
+    maturity_date DATE NOT NULL,
+    instruction DEPOSIT_INSTRUCTION NOT NULL,
+    payout_service_id UUID,
+    state DEPOSIT_STATE NOT NULL,
+    PRIMARY KEY (service_id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (payout_service_id) REFERENCES services ON DELETE SET NULL
+);
+CREATE INDEX deposits_maturity_idx ON deposits (maturity_date) WHERE state = 'OPN';
+
+DROP TYPE IF EXISTS FEE_KIND CASCADE;
+-- TRF Transfer, charged on every outgoing transaction
+-- MNT Monthly maintenance
+-- OVD Overdraft, charged when a transaction overdraws the service
+-- NSF Non-sufficient funds, charged when a transaction fails for lack of funds or
+--     is paid over the overdraft limit
+-- LTE Late payment, charged when a loan installment is overdue
+-- EWD Early withdrawal, charged when a deposit is debited before it matures
+CREATE TYPE FEE_KIND AS ENUM ('TRF', 'MNT', 'OVD', 'NSF', 'LTE', 'EWD');
+
+-- fee is amount plus rate percent of the transferred, overdue or withdrawn
+-- amount
+DROP TABLE IF EXISTS fee_rules CASCADE;
+CREATE TABLE fee_rules (
+    id UUID,
+    service_type SERVICE_TYPE NOT NULL,
+    kind FEE_KIND NOT NULL,
+    currency CURRENCY NOT NULL,
+    amount NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    rate NUMERIC(7, 4) NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    UNIQUE (service_type, kind, currency)
+);
+
+DROP TYPE IF EXISTS HOLD_STATE CASCADE;
+-- ACT Active
+-- CAP Captured
+-- VOI Voided
+-- EXP Expired
+CREATE TYPE HOLD_STATE AS ENUM ('ACT', 'CAP', 'VOI', 'EXP');
+
+DROP TABLE IF EXISTS holds CASCADE;
+CREATE TABLE holds (
+    id UUID,
+    service_id UUID,
+    amount NUMERIC(20, 2),
+    captured NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    state HOLD_STATE,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+CREATE INDEX holds_service_idx ON holds (service_id) WHERE state = 'ACT';
+
+DROP TYPE IF EXISTS BANK_ACCOUNT_PURPOSE CASCADE;
+-- FXP Foreign exchange position
+-- FEE Fee income
+-- INT Interest expense
+-- INC Interest income
+CREATE TYPE BANK_ACCOUNT_PURPOSE AS ENUM ('FXP', 'FEE', 'INT', 'INC');
+
+DROP TABLE IF EXISTS bank_accounts CASCADE;
+CREATE TABLE bank_accounts (
+    purpose BANK_ACCOUNT_PURPOSE,
+    currency CURRENCY,
+    service_id UUID NOT NULL,
+    PRIMARY KEY (purpose, currency),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+
+-- rate is the amount of currency that one unit of base buys, base is not
+-- restricted to CURRENCY since published rates are usually against the euro
+DROP TABLE IF EXISTS exchange_rates CASCADE;
+CREATE TABLE exchange_rates (
+    base VARCHAR(3),
+    currency VARCHAR(3),
+    rate NUMERIC(20, 10) NOT NULL,
+    time TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (base, currency, time)
+);
+
+DROP TABLE IF EXISTS fx_quotes CASCADE;
+CREATE TABLE fx_quotes (
+    id UUID,
+    source CURRENCY NOT NULL,
+    target CURRENCY NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL,
+    expires TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id)
+);
 
 DROP TYPE IF EXISTS TRANSACTION_STATE CASCADE;
 -- PRC Processing
 -- ERR Error
 -- SUC Success
-CREATE TYPE TRANSACTION_STATE AS ENUM ('PRC', 'ERR', 'SUC');
+-- REV Waiting for review
+CREATE TYPE TRANSACTION_STATE AS ENUM ('PRC', 'ERR', 'SUC', 'REV');
+
+DROP TABLE IF EXISTS batches CASCADE;
+CREATE TABLE batches (
+    id UUID,
+    state TRANSACTION_STATE,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    failure_code VARCHAR(40) NOT NULL DEFAULT '',
+    failure_reason VARCHAR(300) NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+);
+
+-- entries are not claimed before available, which is pushed back when a
+-- transient error makes the execution retry
+DROP TABLE IF EXISTS batch_queue CASCADE;
+CREATE TABLE batch_queue (
+    batch_id UUID,
+    enqueued TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    attempts SMALLINT NOT NULL DEFAULT 0,
+    available TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (batch_id),
+    FOREIGN KEY (batch_id) REFERENCES batches ON DELETE CASCADE
+);
 
 DROP TABLE IF EXISTS transactions CASCADE;
 CREATE TABLE transactions (
     id UUID,
     state TRANSACTION_STATE,
     time TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- executed is set when the transaction succeeds, limits are counted on it
+    executed TIMESTAMP WITH TIME ZONE,
     currency CURRENCY,
     amount NUMERIC(20, 2),
     source UUID,
     destination UUID,
+    quote UUID,
+    rate NUMERIC(20, 10) NOT NULL DEFAULT 0,
+    settled_amount NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    reverses UUID,
+    hold UUID,
+    batch UUID,
+    fee VARCHAR(3) NOT NULL DEFAULT '',
+    charges UUID,
+    accept_penalty BOOLEAN NOT NULL DEFAULT false,
+    overdraft VARCHAR(3) NOT NULL DEFAULT '',
+    failure_code VARCHAR(40) NOT NULL DEFAULT '',
+    failure_reason VARCHAR(300) NOT NULL DEFAULT '',
     PRIMARY KEY (id),
     FOREIGN KEY (source) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (destination) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (quote) REFERENCES fx_quotes ON DELETE SET NULL,
+    FOREIGN KEY (reverses) REFERENCES transactions ON DELETE CASCADE,
+    FOREIGN KEY (hold) REFERENCES holds ON DELETE SET NULL,
+    FOREIGN KEY (batch) REFERENCES batches ON DELETE CASCADE,
+    FOREIGN KEY (charges) REFERENCES transactions ON DELETE CASCADE
+);
+CREATE INDEX transactions_source_idx ON transactions (source, id);
+CREATE INDEX transactions_destination_idx ON transactions (destination, id);
+CREATE INDEX transactions_hold_idx ON transactions (hold) WHERE hold IS NOT NULL;
+CREATE INDEX transactions_time_idx ON transactions (time);
+CREATE INDEX transactions_batch_idx ON transactions (batch);
+CREATE INDEX transactions_outgoing_idx ON transactions (source, time)
+    WHERE state = 'SUC';
+CREATE INDEX transactions_reverses_idx ON transactions (reverses);
+CREATE INDEX transactions_charges_idx ON transactions (charges);
+CREATE INDEX transactions_captures_idx ON transactions (source)
+    WHERE hold IS NOT NULL AND state IN ('PRC', 'REV');
+
+-- same retry rules as batch_queue
+DROP TABLE IF EXISTS transaction_queue CASCADE;
+CREATE TABLE transaction_queue (
+    transaction_id UUID,
+    enqueued TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    attempts SMALLINT NOT NULL DEFAULT 0,
+    available TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (transaction_id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions ON DELETE CASCADE
+);
+
+-- one maintenance fee per service and month
+DROP TABLE IF EXISTS maintenance_fees CASCADE;
+CREATE TABLE maintenance_fees (
+    service_id UUID,
+    period DATE,
+    transaction_id UUID NOT NULL,
+    PRIMARY KEY (service_id, period),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES transactions ON DELETE CASCADE
+);
+
+DROP TYPE IF EXISTS LOAN_FREQUENCY CASCADE;
+-- WEK Weekly
+-- BWK Every two weeks
+-- MON Monthly
+CREATE TYPE LOAN_FREQUENCY AS ENUM ('WEK', 'BWK', 'MON');
+
+-- rate is a yearly percentage and term the number of installments, the
+-- schedule is generated when the loan is originated
+DROP TABLE IF EXISTS loans CASCADE;
+CREATE TABLE loans (
+    service_id UUID,
+    principal NUMERIC(20, 2) NOT NULL,
+    rate NUMERIC(7, 4) NOT NULL,
+    term INTEGER NOT NULL,
+    frequency LOAN_FREQUENCY NOT NULL,
+    disbursement_id UUID NOT NULL,
+    originated DATE,
+    PRIMARY KEY (service_id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE,
+    FOREIGN KEY (disbursement_id) REFERENCES services ON DELETE CASCADE
+);
+
+DROP TYPE IF EXISTS INSTALLMENT_STATE CASCADE;
+-- PEN Pending
+-- PAI Paid
+CREATE TYPE INSTALLMENT_STATE AS ENUM ('PEN', 'PAI');
+
+-- late is set once the late fee of an overdue installment was assessed
+DROP TABLE IF EXISTS loan_installments CASCADE;
+CREATE TABLE loan_installments (
+    service_id UUID,
+    number INTEGER,
+    due DATE NOT NULL,
+    interest NUMERIC(20, 2) NOT NULL,
+    principal NUMERIC(20, 2) NOT NULL,
+    late_fee NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    paid_interest NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    paid_principal NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    paid_late_fee NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    late BOOLEAN NOT NULL DEFAULT FALSE,
+    state INSTALLMENT_STATE NOT NULL,
+    PRIMARY KEY (service_id, number),
+    FOREIGN KEY (service_id) REFERENCES loans ON DELETE CASCADE
+);
+CREATE INDEX loan_installments_overdue_idx ON loan_installments (due)
+    WHERE state = 'PEN' AND NOT late;
+
+-- how each repayment of a loan was split
+DROP TABLE IF EXISTS loan_repayments CASCADE;
+CREATE TABLE loan_repayments (
+    transaction_id UUID,
+    service_id UUID NOT NULL,
+    late_fee NUMERIC(20, 2) NOT NULL,
+    interest NUMERIC(20, 2) NOT NULL,
+    principal NUMERIC(20, 2) NOT NULL,
+    PRIMARY KEY (transaction_id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES loans ON DELETE CASCADE
+);
+
+DROP TYPE IF EXISTS REVIEW_DECISION CASCADE;
+-- APR Approved
+-- DEC Declined
+CREATE TYPE REVIEW_DECISION AS ENUM ('APR', 'DEC');
+
+-- transactions parked by a risk rule, decision is null while the review is
+-- pending
+DROP TABLE IF EXISTS transaction_reviews CASCADE;
+CREATE TABLE transaction_reviews (
+    transaction_id UUID,
+    rule VARCHAR(100) NOT NULL,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    decision REVIEW_DECISION,
+    reviewer UUID,
+    PRIMARY KEY (transaction_id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions ON DELETE CASCADE,
+    FOREIGN KEY (reviewer) REFERENCES users ON DELETE SET NULL
+);
+CREATE INDEX transaction_reviews_pending_idx ON transaction_reviews (transaction_id)
+    WHERE decision IS NULL;
+
+DROP TYPE IF EXISTS SCHEDULE_FREQUENCY CASCADE;
+-- ONC Once
+-- DAY Daily
+-- WEK Weekly
+-- MON Monthly
+CREATE TYPE SCHEDULE_FREQUENCY AS ENUM ('ONC', 'DAY', 'WEK', 'MON');
+
+DROP TYPE IF EXISTS SCHEDULE_STATE CASCADE;
+-- ACT Active
+-- CMP Completed
+-- CAN Cancelled
+CREATE TYPE SCHEDULE_STATE AS ENUM ('ACT', 'CMP', 'CAN');
+
+-- end_date null and max_runs 0 mean the schedule runs until cancelled
+DROP TABLE IF EXISTS schedules CASCADE;
+CREATE TABLE schedules (
+    id UUID,
+    service_id UUID,
+    destination UUID,
+    currency CURRENCY,
+    amount NUMERIC(20, 2),
+    frequency SCHEDULE_FREQUENCY,
+    state SCHEDULE_STATE,
+    start TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_run TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_date TIMESTAMP WITH TIME ZONE,
+    max_runs INTEGER NOT NULL DEFAULT 0,
+    runs INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE,
     FOREIGN KEY (destination) REFERENCES services ON DELETE CASCADE
 );
+CREATE INDEX schedules_due_idx ON schedules (next_run) WHERE state = 'ACT';
+
+DROP TYPE IF EXISTS SCHEDULE_OUTCOME CASCADE;
+-- QUE Transaction queued
+-- ERR Transaction could not be created
+CREATE TYPE SCHEDULE_OUTCOME AS ENUM ('QUE', 'ERR');
+
+DROP TABLE IF EXISTS schedule_executions CASCADE;
+CREATE TABLE schedule_executions (
+    id UUID,
+    schedule_id UUID,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    transaction_id UUID,
+    outcome SCHEDULE_OUTCOME,
+    reason VARCHAR(300) NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    FOREIGN KEY (schedule_id) REFERENCES schedules ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES transactions ON DELETE SET NULL
+);
+
+DROP TABLE IF EXISTS journal_entries CASCADE;
+CREATE TABLE journal_entries (
+    id UUID,
+    transaction_id UUID,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions ON DELETE CASCADE
+);
+
+-- negative amounts are debits, positive amounts are credits, balance is the
+-- balance of the service after the posting
+DROP TABLE IF EXISTS postings CASCADE;
+CREATE TABLE postings (
+    id UUID,
+    entry_id UUID,
+    service_id UUID,
+    currency CURRENCY,
+    amount NUMERIC(20, 2),
+    balance NUMERIC(20, 2),
+    PRIMARY KEY (id),
+    FOREIGN KEY (entry_id) REFERENCES journal_entries ON DELETE CASCADE,
+    FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
+);
+CREATE INDEX postings_service_idx ON postings (service_id, id);
+
+-- user_id is the nil UUID for anonymous requests, status is null while the
+-- request is in progress
+DROP TABLE IF EXISTS idempotency_keys CASCADE;
+CREATE TABLE idempotency_keys (
+    user_id UUID,
+    key VARCHAR(100),
+    request_hash CHAR(64) NOT NULL,
+    status SMALLINT,
+    content_type VARCHAR(200) NOT NULL DEFAULT '',
+    response BYTEA,
+    time TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, key)
+);
+
+DROP TABLE IF EXISTS webhooks CASCADE;
+CREATE TABLE webhooks (
+    id UUID,
+    user_id UUID NOT NULL,
+    url VARCHAR(2000) NOT NULL,
+    secret CHAR(64) NOT NULL,
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id),
+    FOREIGN KEY (user_id) REFERENCES users ON DELETE CASCADE
+);
+CREATE INDEX webhooks_user_idx ON webhooks (user_id);
+
+DROP TABLE IF EXISTS webhook_events CASCADE;
+CREATE TABLE webhook_events (
+    webhook_id UUID,
+    event VARCHAR(40),
+    PRIMARY KEY (webhook_id, event),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks ON DELETE CASCADE
+);
+CREATE INDEX webhook_events_event_idx ON webhook_events (event);
+
+DROP TYPE IF EXISTS DELIVERY_STATE CASCADE;
+-- PEN Pending
+-- DLV Delivered
+-- FAI Failed after every attempt
+CREATE TYPE DELIVERY_STATE AS ENUM ('PEN', 'DLV', 'FAI');
+
+-- outbox of webhook events, rows are inserted in the same transaction as the
+-- change they announce
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+CREATE TABLE webhook_deliveries (
+    id UUID,
+    webhook_id UUID NOT NULL,
+    event VARCHAR(40) NOT NULL,
+    payload BYTEA NOT NULL,
+    state DELIVERY_STATE,
+    attempts SMALLINT NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_status SMALLINT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    time TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks ON DELETE CASCADE
+);
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt)
+    WHERE state = 'PEN';
 
 CREATE TABLE user_service (
     user_id UUID,
@@ -71,6 +610,18 @@ CREATE TABLE user_service (
     FOREIGN KEY (service_id) REFERENCES services ON DELETE CASCADE
 );
 
+-- one account per purpose and currency, they are allowed to go negative
+WITH accounts AS (
+    SELECT gen_random_uuid() AS id, p AS purpose, c AS currency
+    FROM unnest(enum_range(NULL::BANK_ACCOUNT_PURPOSE)) AS p
+    CROSS JOIN unnest(enum_range(NULL::CURRENCY)) AS c
+), inserted AS (
+    INSERT INTO services(id, type, state, permissions, currency, init_balance, balance)
+    SELECT id, 'BNK', 'ACT', 7, currency, 0, 0 FROM accounts
+)
+INSERT INTO bank_accounts(purpose, currency, service_id)
+SELECT purpose, currency, id FROM accounts;
+
 CREATE USER back WITH PASSWORD 'root';
 GRANT ALL PRIVILEGES ON DATABASE cardboard_bank TO back;
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO back;
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+)
+
+// importer creates the transactions of a pain.001 or CSV payment file on
+// behalf of a user and prints the status report to stdout.
+func main() {
+	userId := flag.String("user", "", "id of the user initiating the payments")
+	format := flag.String("format", "", "xml or csv, guessed from the file extension by default")
+	flag.Parse()
+
+	if flag.NArg() != 1 || *userId == "" {
+		log.Fatalln("usage: importer -user <id> [-format xml|csv] <file>")
+	}
+	path := flag.Arg(0)
+
+	if *format == "" {
+		*format = "xml"
+		if filepath.Ext(path) == ".csv" {
+			*format = "csv"
+		}
+	}
+
+	id, err := uuid.Parse(*userId)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer file.Close()
+
+	var payments model.PaymentImport
+	switch *format {
+	case "csv":
+		payments, err = dto.ParsePaymentsCSV(file)
+	case "xml":
+		var req dto.Pain001DTO
+		if err = xml.NewDecoder(file).Decode(&req); err == nil {
+			payments, err = req.Parse()
+		}
+	default:
+		log.Fatalf("unknown format %q\n", *format)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	db, err := sql.Open("pgx", os.Getenv("DB_URL"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	usrRepo := repository.NewUsrRepository(db)
+	user, err := usrRepo.FindUser(ctx, id)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// risk rules only apply when the transactions are executed by the api
+	trsRepo := repository.NewTrsRepository(db, nil)
+	if err := trsRepo.ImportPayments(ctx, &payments, user); err != nil {
+		log.Fatalln(err)
+	}
+
+	if *format == "csv" {
+		err = dto.WritePaymentsCSV(os.Stdout, payments)
+	} else {
+		err = dto.WritePain002(os.Stdout, payments)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
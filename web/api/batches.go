@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type BatchesHandlerFactory struct {
+	repo    repository.BatchesRepository
+	mdf     middleware.MiddlewareFactory
+	ownRepo repository.OwnershipRepository
+	wp      *WorkerPool
+}
+
+func NewBatchesHandlerFactory(
+	repo repository.BatchesRepository,
+	mdf middleware.MiddlewareFactory,
+	ownRepo repository.OwnershipRepository,
+	wp *WorkerPool,
+) BatchesHandlerFactory {
+	return BatchesHandlerFactory{repo, mdf, ownRepo, wp}
+}
+
+func (factory *BatchesHandlerFactory) CreateBatch() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(200_000),
+		factory.mdf.Auth,
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		var req dto.CreateBatchRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		batch, err := req.Parse()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		// same rule as ClearanceOrOwnership, applied to every source
+		ctx := r.Context()
+		user := middleware.GetAuthenticatedUser(ctx)
+		if user.Clearance < model.UserClearanceTeller {
+			for _, source := range batch.Sources() {
+				if err := factory.ownRepo.CheckServiceOwnership(
+					ctx, source, user.Id); err != nil {
+					w.WriteHeader(http.StatusForbidden)
+					log.Println(err)
+					return
+				}
+			}
+		}
+
+		if err := factory.repo.CreateBatch(ctx, batch); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		factory.wp.Notify()
+
+		if err := json.NewEncoder(w).Encode(dto.CreateBatchResponseDTO{
+			Id: batch.Id.String(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *BatchesHandlerFactory) ReadSingleBatch() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipBch))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		batchId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		batch, err := factory.repo.FindBatch(r.Context(), batchId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.NewReadBatchResponseDTO(batch)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+)
+
+// Broker fans out transaction state changes to the requests waiting on them,
+// subscribers may see the same state more than once.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan string]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[uuid.UUID]map[chan string]struct{})}
+}
+
+// Subscribe returns a channel with the new states of a transaction, cancel
+// must be called once the subscriber is done.
+func (broker *Broker) Subscribe(id uuid.UUID) (<-chan string, func()) {
+	ch := make(chan string, 4)
+
+	broker.mu.Lock()
+	if broker.subs[id] == nil {
+		broker.subs[id] = make(map[chan string]struct{})
+	}
+	broker.subs[id][ch] = struct{}{}
+	broker.mu.Unlock()
+
+	cancel := func() {
+		broker.mu.Lock()
+		delete(broker.subs[id], ch)
+		if len(broker.subs[id]) == 0 {
+			delete(broker.subs, id)
+		}
+		broker.mu.Unlock()
+	}
+
+	return ch, cancel
+}
+
+// Publish never blocks, a subscriber that is not keeping up misses states
+// and is expected to read the transaction again.
+func (broker *Broker) Publish(id uuid.UUID, state string) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for ch := range broker.subs[id] {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// ListenTransactionEvents forwards the state changes notified by every
+// replica through Postgres to the broker, it reconnects until ctx is done.
+func (broker *Broker) ListenTransactionEvents(ctx context.Context, url string) {
+	for {
+		err := broker.listen(ctx, url)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("transaction events listener: %s\n", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (broker *Broker) listen(ctx context.Context, url string) error {
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx,
+		"listen "+repository.TransactionEventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		idString, state, _ := strings.Cut(notification.Payload, " ")
+		id, err := uuid.Parse(idString)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		broker.Publish(id, state)
+	}
+}
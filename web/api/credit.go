@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type CreditHandlerFactory struct {
+	repo repository.CreditRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewCreditHandlerFactory(
+	repo repository.CreditRepository,
+	mdf middleware.MiddlewareFactory,
+) CreditHandlerFactory {
+	return CreditHandlerFactory{repo, mdf}
+}
+
+func (factory *CreditHandlerFactory) ReadBilling() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+
+		billing, err := factory.repo.FindBilling(r.Context(), serviceId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.NewReadBillingResponseDTO(billing)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *CreditHandlerFactory) UpdateCreditLine() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		var req dto.UpdateCreditLineRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		line, err := req.Parse(serviceId)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.SaveCreditLine(r.Context(), line)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrCreditNotAllowed) {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type DepositsHandlerFactory struct {
+	repo repository.DepositsRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewDepositsHandlerFactory(
+	repo repository.DepositsRepository,
+	mdf middleware.MiddlewareFactory,
+) DepositsHandlerFactory {
+	return DepositsHandlerFactory{repo, mdf}
+}
+
+func (factory *DepositsHandlerFactory) ReadDeposit() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+
+		deposit, err := factory.repo.FindDeposit(r.Context(), serviceId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.NewReadDepositResponseDTO(deposit)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *DepositsHandlerFactory) UpdateDeposit() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		var req dto.UpdateDepositRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		deposit, err := req.Parse(serviceId)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.SaveDeposit(r.Context(), deposit)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrDepositNotAllowed) || errors.Is(err, model.ErrDepositOpen) ||
+			errors.Is(err, model.ErrDepositPayout) {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *DepositsHandlerFactory) UpdateDepositInstruction() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+
+		var req dto.UpdateDepositInstructionRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		deposit, err := req.Parse(serviceId)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.SaveDepositInstruction(r.Context(), deposit)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrDepositNotAllowed) || errors.Is(err, model.ErrDepositPayout) {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
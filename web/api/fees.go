@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type FeesHandlerFactory struct {
+	repo repository.FeesRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewFeesHandlerFactory(
+	repo repository.FeesRepository,
+	mdf middleware.MiddlewareFactory,
+) FeesHandlerFactory {
+	return FeesHandlerFactory{repo, mdf}
+}
+
+// ReadFeeRules lists the fee schedule, it is public to every customer.
+func (factory *FeesHandlerFactory) ReadFeeRules() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		rulesIt, err := factory.repo.FindFeeRules(r.Context(), cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for rule, err := range rulesIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.NewReadFeeRuleResponseDTO(rule)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+// SaveFeeRule creates the rule or replaces the one for the same service type,
+// kind and currency.
+func (factory *FeesHandlerFactory) SaveFeeRule() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller),
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		var req dto.SaveFeeRuleRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		rule, err := req.Parse()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.SaveFeeRule(r.Context(), &rule); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.NewReadFeeRuleResponseDTO(rule)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *FeesHandlerFactory) DeleteFeeRule() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		ruleId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.DeleteFeeRule(r.Context(), ruleId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type FxHandlerFactory struct {
+	repo repository.FxRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewFxHandlerFactory(
+	repo repository.FxRepository,
+	mdf middleware.MiddlewareFactory,
+) FxHandlerFactory {
+	return FxHandlerFactory{repo, mdf}
+}
+
+func (factory *FxHandlerFactory) LoadRates() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1<<20),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			log.Println(err)
+			return
+		}
+
+		var rates []model.ExchangeRate
+		switch mediaType {
+		case "application/xml", "text/xml":
+			var req dto.ECBRatesDTO
+			if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+			rates, err = req.Parse()
+		case "text/csv":
+			rates, err = dto.ParseRatesCSV(r.Body)
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			log.Println("unsupported exchange rate format: " + mediaType)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.StoreRates(r.Context(), rates); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *FxHandlerFactory) ReadRate() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		source := r.URL.Query().Get("source")
+		target := r.URL.Query().Get("target")
+
+		rate, err := factory.repo.FindRate(r.Context(), source, target)
+		if errors.Is(err, repository.ErrRateUnavailable) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.ReadRateResponseDTO{
+			Source: source,
+			Target: target,
+			Rate:   rate.String(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *FxHandlerFactory) CreateQuote() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		var req dto.CreateQuoteRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		quote, err := factory.repo.CreateQuote(r.Context(), req.Source, req.Target)
+		if errors.Is(err, repository.ErrRateUnavailable) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.ReadQuoteResponseDTO{
+			Id:      quote.Id.String(),
+			Source:  quote.Source,
+			Target:  quote.Target,
+			Rate:    quote.Rate.String(),
+			Expires: quote.Expires.Format(time.RFC3339),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type HoldsHandlerFactory struct {
+	repo repository.HoldsRepository
+	mdf  middleware.MiddlewareFactory
+	wp   *WorkerPool
+}
+
+func NewHoldsHandlerFactory(
+	repo repository.HoldsRepository,
+	mdf middleware.MiddlewareFactory,
+	wp *WorkerPool,
+) HoldsHandlerFactory {
+	return HoldsHandlerFactory{repo, mdf, wp}
+}
+
+// holdErrorStatus maps errors of hold operations to a response status.
+func holdErrorStatus(err error) int {
+	var trsErr *model.TransactionError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrTransactionAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrHoldNotActive),
+		errors.Is(err, model.ErrHoldCapturePending),
+		errors.Is(err, repository.ErrServiceNotActive),
+		errors.As(err, &trsErr):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (factory *HoldsHandlerFactory) CreateHold() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv),
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		var req dto.CreateHoldRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		hold, err := req.Parse(serviceId)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.CreateHold(r.Context(), hold); err != nil {
+			w.WriteHeader(holdErrorStatus(err))
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.CreateHoldResponseDTO{
+			Id: hold.Id.String(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *HoldsHandlerFactory) ReadServiceHolds() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		holdsIt, err := factory.repo.FindServiceHolds(r.Context(), serviceId, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for hold, err := range holdsIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.NewReadHoldResponseDTO(hold)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *HoldsHandlerFactory) CaptureHold() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv),
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		holdId, err := uuid.Parse(r.PathValue("hold"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		var req dto.CaptureHoldRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		amount, destination, err := req.Parse()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		transaction, err := factory.repo.CaptureHold(
+			r.Context(), serviceId, holdId, amount, destination)
+		if err != nil {
+			w.WriteHeader(holdErrorStatus(err))
+			log.Println(err)
+			return
+		}
+		factory.wp.Notify()
+
+		if err := json.NewEncoder(w).Encode(dto.CreateTransactionResponseDTO{
+			Id: transaction.Id.String(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *HoldsHandlerFactory) VoidHold() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		holdId, err := uuid.Parse(r.PathValue("hold"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.VoidHold(r.Context(), serviceId, holdId); err != nil {
+			w.WriteHeader(holdErrorStatus(err))
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type LedgerHandlerFactory struct {
+	repo repository.LedgerRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewLedgerHandlerFactory(
+	repo repository.LedgerRepository,
+	mdf middleware.MiddlewareFactory,
+) LedgerHandlerFactory {
+	return LedgerHandlerFactory{repo, mdf}
+}
+
+func (factory *LedgerHandlerFactory) ReadServiceEntries() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		postingsIt, err := factory.repo.FindServiceEntries(r.Context(), serviceId, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for posting, err := range postingsIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.ReadPostingResponseDTO{
+				Id:          posting.Id.String(),
+				Entry:       posting.Entry.String(),
+				Transaction: posting.Transaction.String(),
+				Time:        posting.Time,
+				Currency:    posting.Currency,
+				Amount:      posting.Amount.String(),
+				Balance:     posting.Balance.String(),
+			}); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *LedgerHandlerFactory) ReadServiceReconciliation() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		rec, err := factory.repo.ReconcileService(r.Context(), serviceId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.ReadReconciliationResponseDTO{
+			Service:  rec.Service.String(),
+			Balance:  rec.Balance.String(),
+			Ledger:   rec.Ledger.String(),
+			Postings: rec.Postings,
+			Balanced: rec.Balanced(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *LedgerHandlerFactory) ReadServiceStatement() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		query := r.URL.Query()
+
+		format := query.Get("format")
+		if format == "" {
+			format = model.StatementFormatCSV
+		}
+		writer, ok := dto.StatementWriters[format]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Printf("unknown statement format %q\n", format)
+			return
+		}
+
+		from, to, err := dto.ParseStatementPeriod(query)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		statement, err := factory.repo.FindStatement(r.Context(), serviceId, from, to)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		// rendered up front so a failure can still change the status
+		var body bytes.Buffer
+		if err := writer.Write(&body, statement); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", writer.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(
+			`attachment; filename="statement-%s-%s.%s"`,
+			serviceId.String(),
+			from.UTC().Format("20060102"),
+			writer.Extension))
+		if _, err := body.WriteTo(w); err != nil {
+			log.Println(err)
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
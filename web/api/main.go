@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/ndfsa/cardboard-bank/common/model"
 	"github.com/ndfsa/cardboard-bank/common/repository"
 	"github.com/ndfsa/cardboard-bank/web/middleware"
 )
@@ -21,20 +22,153 @@ func main() {
 		return
 	}
 
-	jobQueue := make(chan model.Transaction, 2)
+	rules, err := loadRiskRules(os.Getenv("RISK_RULES"))
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	log.Printf("loaded %d risk rules\n", len(rules))
 
 	usrRepo := repository.NewUsrRepository(db)
 	srvRepo := repository.NewSrvRepository(db)
-	trsRepo := repository.NewTrsRepository(db, jobQueue)
+	trsRepo := repository.NewTrsRepository(db, rules)
 	ownRepo := repository.NewOwnershipRepository(db)
+	idmRepo := repository.NewIdmRepository(db)
+	ldgRepo := repository.NewLdgRepository(db)
+	fxRepo := repository.NewFxRepository(db)
+	schRepo := repository.NewSchRepository(db)
+	hldRepo := repository.NewHldRepository(db)
+	bchRepo := repository.NewBchRepository(db, rules)
+	limRepo := repository.NewLimRepository(db)
+	whkRepo := repository.NewWhkRepository(db)
+	feeRepo := repository.NewFeeRepository(db)
+	intRepo := repository.NewIntRepository(db)
+	loaRepo := repository.NewLoaRepository(db)
+	crdRepo := repository.NewCrdRepository(db)
+	depRepo := repository.NewDepRepository(db)
+	ovdRepo := repository.NewOvdRepository(db)
+	prmRepo := repository.NewPrmRepository(db)
 
-	mdf := middleware.NewMiddlewareFactory(ownRepo)
+	mdf := middleware.NewMiddlewareFactory(ownRepo, idmRepo)
 
 	srvhf := NewServicesHandlerFactory(srvRepo, mdf)
 	usrhf := NewUsersHandlerFactory(usrRepo, mdf)
-	trshf := NewTransactionsHandlerFactory(trsRepo, mdf, srvRepo)
+	ldghf := NewLedgerHandlerFactory(ldgRepo, mdf)
+	fxhf := NewFxHandlerFactory(fxRepo, mdf)
+	schhf := NewSchedulesHandlerFactory(schRepo, mdf)
+
+	requeued, err := trsRepo.RequeueTransactions(context.Background())
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	log.Printf("requeued %d transactions\n", requeued)
+
+	requeued, err = bchRepo.RequeueBatches(context.Background())
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	log.Printf("requeued %d batches\n", requeued)
+
+	broker := NewBroker()
+	go broker.ListenTransactionEvents(context.Background(), os.Getenv("DB_URL"))
+
+	wp := NewWorkerPool(5, time.Second, trsRepo, bchRepo, broker)
+	defer wp.Stop()
 
-	NewWorkerPool(5, jobQueue, trsRepo)
+	trshf := NewTransactionsHandlerFactory(trsRepo, mdf, srvRepo, &wp, broker)
+	hldhf := NewHoldsHandlerFactory(hldRepo, mdf, &wp)
+	bchhf := NewBatchesHandlerFactory(bchRepo, mdf, ownRepo, &wp)
+	limhf := NewLimitsHandlerFactory(limRepo, mdf)
+	whkhf := NewWebhooksHandlerFactory(whkRepo, mdf)
+	feehf := NewFeesHandlerFactory(feeRepo, mdf)
+	inthf := NewInterestHandlerFactory(intRepo, mdf)
+	loahf := NewLoansHandlerFactory(loaRepo, mdf)
+	crdhf := NewCreditHandlerFactory(crdRepo, mdf)
+	dephf := NewDepositsHandlerFactory(depRepo, mdf)
+	ovdhf := NewOverdraftsHandlerFactory(ovdRepo, mdf)
+	prmhf := NewPermissionsHandlerFactory(prmRepo, mdf, srvRepo)
+
+	webhookClient := newWebhookClient(10 * time.Second)
+
+	scheduler := NewScheduler(
+		SchedulerJob{
+			Name:     "schedules",
+			Interval: 10 * time.Second,
+			Run: func(ctx context.Context) error {
+				ran, err := schRepo.RunDueSchedules(ctx)
+				if ran > 0 {
+					wp.Notify()
+				}
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "holds",
+			Interval: time.Minute,
+			Run: func(ctx context.Context) error {
+				_, err := hldRepo.ExpireHolds(ctx)
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "webhooks",
+			Interval: 5 * time.Second,
+			Run: func(ctx context.Context) error {
+				return deliverWebhooks(ctx, whkRepo, webhookClient)
+			},
+		},
+		SchedulerJob{
+			Name:     "fees",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := feeRepo.ChargeMaintenanceFees(ctx)
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "interest",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := intRepo.AccrueInterest(ctx)
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "loans",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := loaRepo.ChargeLateFees(ctx)
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "billing",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := crdRepo.RunBillingCycles(ctx)
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "deposits",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := depRepo.MatureDeposits(ctx)
+				return err
+			},
+		},
+		SchedulerJob{
+			Name:     "overdrafts",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := ovdRepo.AccrueOverdraftInterest(ctx)
+				return err
+			},
+		},
+	)
+	defer scheduler.Stop()
 
 	http.Handle("GET /users/{id}", usrhf.ReadSingleUser())
 	http.Handle("GET /users", usrhf.ReadMultipleUsers())
@@ -47,15 +181,80 @@ func main() {
 
 	http.Handle("GET /services/{id}", srvhf.ReadSingleService())
 	http.Handle("GET /services", srvhf.ReadMultipleServices())
+	http.Handle("GET /services/requests", srvhf.ReadRequestedServices())
 	http.Handle("POST /services", srvhf.CreateService())
 	http.Handle("PUT /services/{id}", srvhf.UpdateService())
 	http.Handle("DELETE /services/{id}", srvhf.DeleteService())
+	http.Handle("GET /services/{id}/states", srvhf.ReadServiceStateChanges())
+
+	http.Handle("GET /services/{id}/permissions", prmhf.ReadPermissions())
+	http.Handle("PUT /services/{id}/permissions/{permission}", prmhf.UpdatePermission())
+	http.Handle("GET /services/{id}/permissions/history", prmhf.ReadPermissionChanges())
 
 	http.Handle("GET /services/{id}/transactions", trshf.ReadServiceTransactions())
+	http.Handle("GET /services/{id}/entries", ldghf.ReadServiceEntries())
+	http.Handle("GET /services/{id}/reconciliation", ldghf.ReadServiceReconciliation())
+	http.Handle("GET /services/{id}/statements", ldghf.ReadServiceStatement())
+
+	http.Handle("GET /services/{id}/schedules", schhf.ReadServiceSchedules())
+	http.Handle("POST /services/{id}/schedules", schhf.CreateSchedule())
+	http.Handle("GET /services/{id}/schedules/{schedule}", schhf.ReadSingleSchedule())
+	http.Handle("PUT /services/{id}/schedules/{schedule}", schhf.UpdateSchedule())
+	http.Handle("DELETE /services/{id}/schedules/{schedule}", schhf.CancelSchedule())
+	http.Handle("GET /services/{id}/schedules/{schedule}/executions",
+		schhf.ReadScheduleExecutions())
+
+	http.Handle("GET /services/{id}/holds", hldhf.ReadServiceHolds())
+	http.Handle("POST /services/{id}/holds", hldhf.CreateHold())
+	http.Handle("POST /services/{id}/holds/{hold}/capture", hldhf.CaptureHold())
+	http.Handle("POST /services/{id}/holds/{hold}/void", hldhf.VoidHold())
+
+	http.Handle("GET /services/{id}/limits", limhf.ReadLimits())
+	http.Handle("PUT /services/{id}/limits", limhf.UpdateLimits())
+
+	http.Handle("GET /services/{id}/interest", inthf.ReadInterest())
+	http.Handle("PUT /services/{id}/interest", inthf.UpdateInterest())
+
+	http.Handle("GET /services/{id}/loan", loahf.ReadLoan())
+	http.Handle("PUT /services/{id}/loan", loahf.UpdateLoan())
+
+	http.Handle("GET /services/{id}/billing", crdhf.ReadBilling())
+	http.Handle("PUT /services/{id}/billing", crdhf.UpdateCreditLine())
+
+	http.Handle("GET /services/{id}/deposit", dephf.ReadDeposit())
+	http.Handle("PUT /services/{id}/deposit", dephf.UpdateDeposit())
+	http.Handle("PUT /services/{id}/deposit/instruction", dephf.UpdateDepositInstruction())
+
+	http.Handle("GET /services/{id}/overdraft", ovdhf.ReadOverdraft())
+	http.Handle("PUT /services/{id}/overdraft", ovdhf.UpdateOverdraft())
+	http.Handle("DELETE /services/{id}/overdraft", ovdhf.DeleteOverdraft())
 
 	http.Handle("GET /transactions/{id}", trshf.ReadSingleTransaction())
 	http.Handle("GET /transactions", trshf.ReadMultipleTransactions())
 	http.Handle("POST /transactions", trshf.CreateTransaction())
+	http.Handle("POST /transactions/imports", trshf.ImportPayments())
+	http.Handle("POST /transactions/{id}/reversal", trshf.CreateReversal())
+	http.Handle("GET /transactions/{id}/events", trshf.StreamTransactionEvents())
+	http.Handle("GET /transactions/reviews", trshf.ReadPendingReviews())
+	http.Handle("POST /transactions/{id}/approve", trshf.ReviewTransaction(true))
+	http.Handle("POST /transactions/{id}/decline", trshf.ReviewTransaction(false))
+
+	http.Handle("POST /fx/rates", fxhf.LoadRates())
+	http.Handle("GET /fx/rates", fxhf.ReadRate())
+	http.Handle("POST /fx/quotes", fxhf.CreateQuote())
+
+	http.Handle("GET /batches/{id}", bchhf.ReadSingleBatch())
+	http.Handle("POST /batches", bchhf.CreateBatch())
+
+	http.Handle("GET /webhooks", whkhf.ReadUserWebhooks())
+	http.Handle("POST /webhooks", whkhf.CreateWebhook())
+	http.Handle("DELETE /webhooks/{id}", whkhf.DeleteWebhook())
+	http.Handle("GET /webhooks/{id}/deliveries", whkhf.ReadWebhookDeliveries())
+	http.Handle("POST /webhooks/{id}/deliveries/{delivery}/redeliver", whkhf.Redeliver())
+
+	http.Handle("GET /fees", feehf.ReadFeeRules())
+	http.Handle("POST /fees", feehf.SaveFeeRule())
+	http.Handle("DELETE /fees/{id}", feehf.DeleteFeeRule())
 
 	http.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
 	http.HandleFunc("GET /hello", func(w http.ResponseWriter, r *http.Request) {
@@ -66,5 +265,4 @@ func main() {
 	if err := http.ListenAndServe(":"+os.Getenv("PORT"), nil); !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
-	close(jobQueue)
 }
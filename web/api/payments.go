@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+// maxImportSize caps the size of a payment import file.
+const maxImportSize = 10 << 20
+
+// ImportPayments takes a pain.001 XML file, or a CSV file when the content
+// type is text/csv, and answers with the status of every instruction in the
+// same format.
+func (factory *TransactionsHandlerFactory) ImportPayments() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(maxImportSize),
+		factory.mdf.Auth,
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var payments model.PaymentImport
+		var err error
+		if mediaType == "text/csv" {
+			payments, err = dto.ParsePaymentsCSV(r.Body)
+		} else {
+			var req dto.Pain001DTO
+			if err = xml.NewDecoder(r.Body).Decode(&req); err == nil {
+				payments, err = req.Parse()
+			}
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		user := middleware.GetAuthenticatedUser(r.Context())
+		if err := factory.repo.ImportPayments(r.Context(), &payments, user); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		factory.wp.Notify()
+
+		if mediaType == "text/csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			err = dto.WritePaymentsCSV(w, payments)
+		} else {
+			w.Header().Set("Content-Type", "application/xml")
+			err = dto.WritePain002(w, payments)
+		}
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+)
+
+// loadRiskRules reads the risk rules from a JSON file, no file means no rules
+// and every transaction is allowed.
+func loadRiskRules(path string) (model.RiskRules, error) {
+	if path == "" {
+		return model.RiskRules{}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var data dto.RiskRulesDTO
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return data.Parse()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SchedulerJob is a task the api runs periodically in the background, jobs
+// must be safe to run from several replicas at the same time.
+type SchedulerJob struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs []SchedulerJob
+	done chan struct{}
+}
+
+func (s *Scheduler) loop(job SchedulerJob) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(context.Background()); err != nil {
+			log.Printf("job %s failed: %s\n", job.Name, err)
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) Stop() {
+	close(s.done)
+}
+
+func NewScheduler(jobs ...SchedulerJob) Scheduler {
+	s := Scheduler{
+		jobs: jobs,
+		done: make(chan struct{}),
+	}
+
+	for _, job := range jobs {
+		if job.Interval <= 0 {
+			panic("job interval must be > 0")
+		}
+		go s.loop(job)
+	}
+
+	return s
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+type SchedulesHandlerFactory struct {
+	repo repository.SchedulesRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewSchedulesHandlerFactory(
+	repo repository.SchedulesRepository,
+	mdf middleware.MiddlewareFactory,
+) SchedulesHandlerFactory {
+	return SchedulesHandlerFactory{repo, mdf}
+}
+
+func (factory *SchedulesHandlerFactory) CreateSchedule() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv),
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		var req dto.CreateScheduleRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		schedule, err := req.Parse(serviceId)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.CreateSchedule(r.Context(), schedule); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.CreateScheduleResponseDTO{
+			Id: schedule.Id.String(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *SchedulesHandlerFactory) ReadServiceSchedules() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		schedulesIt, err := factory.repo.FindServiceSchedules(r.Context(), serviceId, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for schedule, err := range schedulesIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.NewReadScheduleResponseDTO(schedule)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *SchedulesHandlerFactory) ReadSingleSchedule() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		schedule, ok := factory.findSchedule(w, r)
+		if !ok {
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.NewReadScheduleResponseDTO(schedule)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *SchedulesHandlerFactory) UpdateSchedule() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		schedule, ok := factory.findSchedule(w, r)
+		if !ok {
+			return
+		}
+
+		var req dto.UpdateScheduleRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := req.Apply(&schedule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.UpdateSchedule(r.Context(), schedule); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *SchedulesHandlerFactory) CancelSchedule() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		schedule, ok := factory.findSchedule(w, r)
+		if !ok {
+			return
+		}
+
+		if err := schedule.Cancel(); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.UpdateSchedule(r.Context(), schedule); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *SchedulesHandlerFactory) ReadScheduleExecutions() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		schedule, ok := factory.findSchedule(w, r)
+		if !ok {
+			return
+		}
+
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		executionsIt, err := factory.repo.FindScheduleExecutions(r.Context(), schedule.Id, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for execution, err := range executionsIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			res := dto.ReadScheduleExecutionResponseDTO{
+				Id:               execution.Id.String(),
+				Time:             execution.Time,
+				Outcome:          execution.Outcome,
+				Reason:           execution.Reason,
+				TransactionState: execution.TransactionState,
+				FailureCode:      execution.FailureCode,
+			}
+			if execution.Transaction.Valid {
+				res.Transaction = execution.Transaction.UUID.String()
+			}
+
+			if err := encoder.Encode(res); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+// findSchedule loads the schedule in the path, it writes the error response
+// and returns false if it can't.
+func (factory *SchedulesHandlerFactory) findSchedule(
+	w http.ResponseWriter, r *http.Request,
+) (model.Schedule, bool) {
+	serviceId, _ := uuid.Parse(r.PathValue("id"))
+	scheduleId, err := uuid.Parse(r.PathValue("schedule"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		log.Println(err)
+		return model.Schedule{}, false
+	}
+
+	schedule, err := factory.repo.FindSchedule(r.Context(), serviceId, scheduleId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		log.Println(err)
+		return model.Schedule{}, false
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Println(err)
+		return model.Schedule{}, false
+	}
+
+	return schedule, true
+}
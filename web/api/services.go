@@ -1,7 +1,9 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
@@ -111,14 +113,7 @@ func (factory *ServicesHandlerFactory) ReadSingleService() http.Handler {
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(dto.ReadServiceResponseDTO{
-			Id:          service.Id.String(),
-			Type:        service.Type,
-			State:       service.State,
-			Currency:    service.Currency,
-			InitBalance: service.InitBalance.String(),
-			Balance:     service.Balance.String(),
-		}); err != nil {
+		if err := json.NewEncoder(w).Encode(dto.NewReadServiceResponseDTO(service)); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
 			return
@@ -163,14 +158,7 @@ func (factory *ServicesHandlerFactory) ReadMultipleServices() http.Handler {
 				log.Println(err)
 				return
 			}
-			if err := encoder.Encode(dto.ReadServiceResponseDTO{
-				Id:          service.Id.String(),
-				Type:        service.Type,
-				State:       service.State,
-				Currency:    service.Currency,
-				InitBalance: service.InitBalance.String(),
-				Balance:     service.Balance.String(),
-			}); err != nil {
+			if err := encoder.Encode(dto.NewReadServiceResponseDTO(service)); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				log.Println(err)
 				return
@@ -195,10 +183,34 @@ func (factory *ServicesHandlerFactory) UpdateService() http.Handler {
 			return
 		}
 
-		if err := factory.repo.UpdateService(r.Context(), model.Service{
-			Id:    serviceId,
-			State: req.State,
-		}); err != nil {
+		user := middleware.GetAuthenticatedUser(r.Context())
+		change, err := req.Parse(serviceId, user.Id)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.UpdateService(r.Context(), change,
+			user.Clearance >= model.UserClearanceTeller)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrTransitionForbidden) {
+			w.WriteHeader(http.StatusForbidden)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrInvalidTransition) ||
+			errors.Is(err, model.ErrServiceNotSettled) ||
+			errors.Is(err, model.ErrLoanDisbursement) {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
 			return
@@ -216,10 +228,50 @@ func (factory *ServicesHandlerFactory) DeleteService() http.Handler {
 		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
 	f := func(w http.ResponseWriter, r *http.Request) {
 		serviceId, _ := uuid.Parse(r.PathValue("id"))
-		if err := factory.repo.UpdateService(r.Context(), model.Service{
-			Id:    serviceId,
-			State: model.ServiceStateClosed,
-		}); err != nil {
+		// DELETE requests usually have no body, the reason can be given as a
+		// query parameter instead
+		req := dto.DeleteServiceRequestDTO{Reason: r.URL.Query().Get("reason")}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		}
+
+		user := middleware.GetAuthenticatedUser(r.Context())
+		change, err := req.Parse(serviceId, user.Id)
+		if errors.Is(err, model.ErrReasonRequired) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.UpdateService(r.Context(), change,
+			user.Clearance >= model.UserClearanceTeller)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrTransitionForbidden) {
+			w.WriteHeader(http.StatusForbidden)
+			log.Println(err)
+			return
+		}
+		if errors.Is(err, model.ErrInvalidTransition) ||
+			errors.Is(err, model.ErrServiceNotSettled) ||
+			errors.Is(err, model.ErrLoanDisbursement) {
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		}
+		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
 			return
@@ -252,14 +304,7 @@ func (factory *ServicesHandlerFactory) ReadUserServices() http.Handler {
 				return
 			}
 
-			if err := encoder.Encode(dto.ReadServiceResponseDTO{
-				Id:          service.Id.String(),
-				Type:        service.Type,
-				State:       service.State,
-				Currency:    service.Currency,
-				InitBalance: service.InitBalance.String(),
-				Balance:     service.Balance.String(),
-			}); err != nil {
+			if err := encoder.Encode(dto.NewReadServiceResponseDTO(service)); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				log.Println(err)
 				return
@@ -274,7 +319,8 @@ func (factory *ServicesHandlerFactory) CreateUserService() http.Handler {
 		factory.mdf.Logger,
 		factory.mdf.UploadLimit(1000),
 		factory.mdf.Auth,
-		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipUsr))
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipUsr),
+		factory.mdf.Idempotency)
 	f := func(w http.ResponseWriter, r *http.Request) {
 		userId, _ := uuid.Parse(r.PathValue("id"))
 		var req dto.CreateServiceRequestDTO
@@ -313,3 +359,96 @@ func (factory *ServicesHandlerFactory) CreateUserService() http.Handler {
 	}
 	return mid(http.HandlerFunc(f))
 }
+
+func (factory *ServicesHandlerFactory) ReadRequestedServices() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		servicesIt, err := factory.repo.FindRequestedServices(r.Context(), cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for service, err := range servicesIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+			if err := encoder.Encode(dto.NewReadServiceResponseDTO(service)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *ServicesHandlerFactory) ReadServiceStateChanges() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipSrv))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		serviceId, _ := uuid.Parse(r.PathValue("id"))
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		changesIt, err := factory.repo.FindStateChanges(r.Context(), serviceId, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for change, err := range changesIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+			if err := encoder.Encode(dto.NewReadStateChangeResponseDTO(change)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ndfsa/cardboard-bank/common/model"
@@ -12,25 +17,33 @@ import (
 	"github.com/ndfsa/cardboard-bank/web/middleware"
 )
 
+// maxWait caps how long a client can wait for a transaction to complete.
+const maxWait = 30 * time.Second
+
 type TransactionsHandlerFactory struct {
 	repo    repository.TransactionsRepository
 	mdf     middleware.MiddlewareFactory
 	srvRepo repository.ServicesRepository
+	wp      *WorkerPool
+	broker  *Broker
 }
 
 func NewTransactionsHandlerFactory(
 	repo repository.TransactionsRepository,
 	mdf middleware.MiddlewareFactory,
 	srvRepo repository.ServicesRepository,
+	wp *WorkerPool,
+	broker *Broker,
 ) TransactionsHandlerFactory {
-	return TransactionsHandlerFactory{repo, mdf, srvRepo}
+	return TransactionsHandlerFactory{repo, mdf, srvRepo, wp, broker}
 }
 
 func (factory *TransactionsHandlerFactory) CreateTransaction() http.Handler {
 	mid := middleware.Chain(
 		factory.mdf.Logger,
 		factory.mdf.UploadLimit(1000),
-		factory.mdf.Auth)
+		factory.mdf.Auth,
+		factory.mdf.Idempotency)
 	f := func(w http.ResponseWriter, r *http.Request) {
 		var req dto.CreateTransactionRequestDTO
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -46,15 +59,48 @@ func (factory *TransactionsHandlerFactory) CreateTransaction() http.Handler {
 			return
 		}
 
+		var wait time.Duration
+		if waitString := r.URL.Query().Get("wait"); waitString != "" {
+			wait, err = time.ParseDuration(waitString)
+			if err != nil || wait < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println("invalid wait duration")
+				return
+			}
+			wait = min(wait, maxWait)
+		}
+
+		// subscribe before the transaction can be processed to not miss it
+		events, cancel := factory.broker.Subscribe(transaction.Id)
+		defer cancel()
+
 		if err := factory.repo.CreateTransaction(r.Context(), transaction); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
 			return
 		}
+		factory.wp.Notify()
 
-		if err := json.NewEncoder(w).Encode(dto.CreateTransactionResponseDTO{
+		res := dto.CreateTransactionResponseDTO{
 			Id: transaction.Id.String(),
-		}); err != nil {
+		}
+
+		if wait > 0 {
+			waitForTransaction(r.Context(), events, wait)
+
+			transaction, err = factory.repo.FindTransaction(r.Context(), transaction.Id)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			res.State = transaction.State
+			res.FailureCode = transaction.FailureCode
+			res.FailureReason = transaction.FailureReason
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
 			w.WriteHeader(http.StatusCreated)
 			log.Println(err)
 			return
@@ -84,15 +130,7 @@ func (factory *TransactionsHandlerFactory) ReadSingleTransaction() http.Handler
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(dto.ReadTransactionResponseDTO{
-			Id:          transaction.Id.String(),
-			State:       transaction.State,
-			Time:        transaction.Time,
-			Currency:    transaction.Currency,
-			Amount:      transaction.Amount.String(),
-			Source:      transaction.Source.String(),
-			Destination: transaction.Destination.String(),
-		}); err != nil {
+		if err := json.NewEncoder(w).Encode(newReadTransactionResponseDTO(transaction)); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
 			return
@@ -122,7 +160,21 @@ func (factory *TransactionsHandlerFactory) ReadMultipleTransactions() http.Handl
 			cursor = uuid.UUID{}
 		}
 
-		transactionsIt, err := factory.repo.FindAllTransactions(r.Context(), cursor)
+		filter, err := dto.ParseTransactionFilter(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		// direction is relative to a service, this listing has none
+		if filter.Direction != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println("direction filter needs a service")
+			return
+		}
+
+		transactionsIt, err := factory.repo.FindAllTransactions(r.Context(), filter, cursor)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
@@ -138,15 +190,7 @@ func (factory *TransactionsHandlerFactory) ReadMultipleTransactions() http.Handl
 				return
 			}
 
-			if err := encoder.Encode(dto.ReadTransactionResponseDTO{
-				Id:          transaction.Id.String(),
-				State:       transaction.State,
-				Time:        transaction.Time,
-				Currency:    transaction.Currency,
-				Amount:      transaction.Amount.String(),
-				Source:      transaction.Source.String(),
-				Destination: transaction.Destination.String(),
-			}); err != nil {
+			if err := encoder.Encode(newReadTransactionResponseDTO(transaction)); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				log.Println(err)
 				return
@@ -178,7 +222,15 @@ func (factory *TransactionsHandlerFactory) ReadServiceTransactions() http.Handle
 			cursor = uuid.UUID{}
 		}
 
-		transactionsIt, err := factory.repo.FindServiceTransactions(r.Context(), serviceId, cursor)
+		filter, err := dto.ParseTransactionFilter(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		transactionsIt, err := factory.repo.FindServiceTransactions(
+			r.Context(), serviceId, filter, cursor)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			log.Println(err)
@@ -194,14 +246,164 @@ func (factory *TransactionsHandlerFactory) ReadServiceTransactions() http.Handle
 				return
 			}
 
-			if err := encoder.Encode(dto.ReadTransactionResponseDTO{
-				Id:          transaction.Id.String(),
-				State:       transaction.State,
-				Time:        transaction.Time,
-				Currency:    transaction.Currency,
-				Amount:      transaction.Amount.String(),
-				Source:      transaction.Source.String(),
-				Destination: transaction.Destination.String(),
+			if err := encoder.Encode(newReadTransactionResponseDTO(transaction)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func newReadTransactionResponseDTO(transaction model.Transaction) dto.ReadTransactionResponseDTO {
+	return dto.ReadTransactionResponseDTO{
+		Id:          transaction.Id.String(),
+		State:       transaction.State,
+		Time:        transaction.Time,
+		Currency:    transaction.Currency,
+		Amount:      transaction.Amount.String(),
+		Source:      transaction.Source.String(),
+		Destination: transaction.Destination.String(),
+
+		Quote:         nullUUIDString(transaction.Quote),
+		Rate:          transaction.Rate.String(),
+		SettledAmount: transaction.SettledAmount.String(),
+
+		Reverses:  nullUUIDString(transaction.Reverses),
+		Reversals: uuidStrings(transaction.Reversals),
+		Hold:      nullUUIDString(transaction.Hold),
+
+		Fee:     transaction.Fee,
+		Charges: nullUUIDString(transaction.Charges),
+		Fees:    uuidStrings(transaction.Fees),
+
+		AcceptPenalty: transaction.AcceptPenalty,
+		Overdraft:     transaction.Overdraft,
+
+		FailureCode:   transaction.FailureCode,
+		FailureReason: transaction.FailureReason,
+	}
+}
+
+func nullUUIDString(id uuid.NullUUID) string {
+	if !id.Valid {
+		return ""
+	}
+	return id.UUID.String()
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, id.String())
+	}
+	return res
+}
+
+func (factory *TransactionsHandlerFactory) CreateReversal() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller),
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		transactionId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		var req dto.CreateReversalRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		amount, err := req.Parse()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		reversal, err := factory.repo.CreateReversal(r.Context(), transactionId, amount)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		case errors.Is(err, model.ErrTransactionAmount):
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		case errors.Is(err, model.ErrTransactionNotReversible),
+			errors.Is(err, model.ErrReversalExceedsOriginal):
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		case err != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		factory.wp.Notify()
+
+		if err := json.NewEncoder(w).Encode(dto.CreateTransactionResponseDTO{
+			Id: reversal.Id.String(),
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *TransactionsHandlerFactory) ReadPendingReviews() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		reviewsIt, err := factory.repo.FindPendingReviews(r.Context(), cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for review, err := range reviewsIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.ReadReviewResponseDTO{
+				Transaction: review.Transaction.String(),
+				Rule:        review.Rule,
+				Time:        review.Time,
 			}); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				log.Println(err)
@@ -211,3 +413,160 @@ func (factory *TransactionsHandlerFactory) ReadServiceTransactions() http.Handle
 	}
 	return mid(http.HandlerFunc(f))
 }
+
+// ReviewTransaction approves or declines a transaction parked by a risk rule.
+func (factory *TransactionsHandlerFactory) ReviewTransaction(approve bool) http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.Clearance(model.UserClearanceTeller))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		transactionId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		reviewer := middleware.GetAuthenticatedUser(r.Context())
+		err = factory.repo.ReviewTransaction(r.Context(), transactionId, reviewer.Id, approve)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		case errors.Is(err, model.ErrTransactionNotInReview):
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		case err != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if approve {
+			factory.broker.Publish(transactionId, model.TransactionStateProcessing)
+			factory.wp.Notify()
+		} else {
+			factory.broker.Publish(transactionId, model.TransactionStateError)
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+// waitForTransaction blocks until the transaction leaves processing, the
+// timeout elapses or the client goes away.
+func waitForTransaction(ctx context.Context, events <-chan string, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			return
+		case state := <-events:
+			if state != model.TransactionStateProcessing {
+				return
+			}
+		}
+	}
+}
+
+// StreamTransactionEvents sends the transaction as a server-sent event every
+// time its state changes, the stream ends when the transaction succeeds or
+// fails.
+func (factory *TransactionsHandlerFactory) StreamTransactionEvents() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipTrs))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		transactionId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		events, cancel := factory.broker.Subscribe(transactionId)
+		defer cancel()
+
+		ctx := r.Context()
+		transaction, err := factory.repo.FindTransaction(ctx, transactionId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		rc := http.NewResponseController(w)
+		send := func(transaction model.Transaction) error {
+			if _, err := fmt.Fprint(w, "event: state\ndata: "); err != nil {
+				return err
+			}
+			if err := json.NewEncoder(w).Encode(
+				newReadTransactionResponseDTO(transaction)); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(w, "\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		if err := send(transaction); err != nil {
+			log.Println(err)
+			return
+		}
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		for transaction.State == model.TransactionStateProcessing ||
+			transaction.State == model.TransactionStateReview {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					log.Println(err)
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					log.Println(err)
+					return
+				}
+			case state := <-events:
+				if state == transaction.State {
+					continue
+				}
+
+				transaction, err = factory.repo.FindTransaction(ctx, transactionId)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+
+				if err := send(transaction); err != nil {
+					log.Println(err)
+					return
+				}
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
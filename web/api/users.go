@@ -27,7 +27,8 @@ func NewUsersHandlerFactory(
 func (factory *UsersHandlerFactory) CreateUser() http.Handler {
 	mid := middleware.Chain(
 		factory.mdf.Logger,
-		factory.mdf.UploadLimit(1000))
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Idempotency)
 	f := func(w http.ResponseWriter, r *http.Request) {
 		var req dto.CreateUserRequestDTO
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
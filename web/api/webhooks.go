@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/ndfsa/cardboard-bank/common/repository"
+	"github.com/ndfsa/cardboard-bank/web/dto"
+	"github.com/ndfsa/cardboard-bank/web/middleware"
+)
+
+// maxDeliveriesPerRun bounds how long a single run of the dispatcher takes.
+const maxDeliveriesPerRun = 100
+
+type WebhooksHandlerFactory struct {
+	repo repository.WebhooksRepository
+	mdf  middleware.MiddlewareFactory
+}
+
+func NewWebhooksHandlerFactory(
+	repo repository.WebhooksRepository,
+	mdf middleware.MiddlewareFactory,
+) WebhooksHandlerFactory {
+	return WebhooksHandlerFactory{repo, mdf}
+}
+
+func (factory *WebhooksHandlerFactory) CreateWebhook() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(5000),
+		factory.mdf.Auth,
+		factory.mdf.Idempotency)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		var req dto.CreateWebhookRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		user := middleware.GetAuthenticatedUser(r.Context())
+		webhook, err := req.Parse(user)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := checkWebhookHost(r.Context(), webhook.Url); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+
+		if err := factory.repo.CreateWebhook(r.Context(), webhook); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dto.CreateWebhookResponseDTO{
+			Id:     webhook.Id.String(),
+			Secret: webhook.Secret,
+		}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *WebhooksHandlerFactory) ReadUserWebhooks() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth)
+	f := func(w http.ResponseWriter, r *http.Request) {
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		user := middleware.GetAuthenticatedUser(r.Context())
+		webhooksIt, err := factory.repo.FindUserWebhooks(r.Context(), user.Id, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for webhook, err := range webhooksIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.NewReadWebhookResponseDTO(webhook)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *WebhooksHandlerFactory) DeleteWebhook() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipWhk))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		webhookId, _ := uuid.Parse(r.PathValue("id"))
+
+		err := factory.repo.DeleteWebhook(r.Context(), webhookId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *WebhooksHandlerFactory) ReadWebhookDeliveries() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipWhk))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		webhookId, _ := uuid.Parse(r.PathValue("id"))
+		cursorString := r.URL.Query().Get("cursor")
+		var cursor uuid.UUID
+		if cursorString != "" {
+			var err error
+			cursor, err = uuid.Parse(cursorString)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				log.Println(err)
+				return
+			}
+		} else {
+			cursor = uuid.UUID{}
+		}
+
+		deliveriesIt, err := factory.repo.FindWebhookDeliveries(r.Context(), webhookId, cursor)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "")
+		for delivery, err := range deliveriesIt {
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+
+			if err := encoder.Encode(dto.NewReadDeliveryResponseDTO(delivery)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Println(err)
+				return
+			}
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+func (factory *WebhooksHandlerFactory) Redeliver() http.Handler {
+	mid := middleware.Chain(
+		factory.mdf.Logger,
+		factory.mdf.UploadLimit(1000),
+		factory.mdf.Auth,
+		factory.mdf.ClearanceOrOwnership(model.UserClearanceTeller, middleware.OwnershipWhk))
+	f := func(w http.ResponseWriter, r *http.Request) {
+		webhookId, _ := uuid.Parse(r.PathValue("id"))
+		deliveryId, err := uuid.Parse(r.PathValue("delivery"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+
+		err = factory.repo.Redeliver(r.Context(), webhookId, deliveryId)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			log.Println(err)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+	}
+	return mid(http.HandlerFunc(f))
+}
+
+// checkWebhookHost resolves the host of a webhook url and fails if any of its
+// addresses is not public.
+func checkWebhookHost(ctx context.Context, endpoint string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := model.CheckWebhookAddress(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newWebhookClient returns a client that refuses to connect to addresses that
+// are not public, the check runs after the name is resolved so it also covers
+// redirects and names that change what they point to after registration.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return model.CheckWebhookAddress(addrPort.Addr())
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
+
+// sendDelivery posts the payload of a delivery to its webhook, the signature
+// covers the timestamp header and the body.
+func sendDelivery(client *http.Client) func(model.Webhook, model.Delivery) (int, error) {
+	return func(webhook model.Webhook, delivery model.Delivery) (int, error) {
+		req, err := http.NewRequest(http.MethodPost, webhook.Url,
+			bytes.NewReader(delivery.Payload))
+		if err != nil {
+			return 0, err
+		}
+
+		timestamp := time.Now().Unix()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Webhook-Id", delivery.Id.String())
+		req.Header.Set("Webhook-Event", delivery.Event)
+		req.Header.Set("Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+		req.Header.Set("Webhook-Signature",
+			"sha256="+webhook.Sign(timestamp, delivery.Payload))
+
+		res, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer res.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+		return res.StatusCode, nil
+	}
+}
+
+// deliverWebhooks sends every delivery that is due, up to
+// maxDeliveriesPerRun.
+func deliverWebhooks(
+	ctx context.Context,
+	repo repository.WebhooksRepository,
+	client *http.Client,
+) error {
+	send := sendDelivery(client)
+	for range maxDeliveriesPerRun {
+		delivery, err := repo.DeliverNext(ctx, send)
+		if errors.Is(err, repository.ErrQueueEmpty) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if delivery.State != model.DeliveryStateDelivered {
+			log.Printf("delivery %s failed: %s\n", delivery.Id.String(), delivery.LastError)
+		}
+	}
+
+	return nil
+}
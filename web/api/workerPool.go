@@ -1,40 +1,136 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ndfsa/cardboard-bank/common/model"
 	"github.com/ndfsa/cardboard-bank/common/repository"
 )
 
 type WorkerPool struct {
-	jobQueue <-chan model.Transaction
-	repo     repository.TransactionsRepository
-	workers  int
-	queue    int
+	repo    repository.TransactionsRepository
+	bchRepo repository.BatchesRepository
+	broker  *Broker
+	workers int
+	poll    time.Duration
+	wake    chan struct{}
+	done    chan struct{}
 }
 
 func (wp *WorkerPool) worker() {
-	for transaction := range wp.jobQueue {
-		if err := wp.repo.ExecuteTransaction(transaction); err != nil {
-			log.Printf("transaction %s failed: %s\n", transaction.Id.String(), err)
+	for {
+		busy := wp.processTransaction()
+		busy = wp.processBatch() || busy
+
+		if !busy && !wp.wait() {
+			return
+		}
+
+		select {
+		case <-wp.done:
+			return
+		default:
 		}
 	}
 }
 
+// processTransaction executes the next queued transaction, it returns false
+// if there was nothing to do.
+func (wp *WorkerPool) processTransaction() bool {
+	transaction, err := wp.repo.ProcessTransaction(context.Background())
+	switch {
+	case errors.Is(err, repository.ErrQueueEmpty):
+		return false
+	case err != nil && (transaction.Id == uuid.UUID{}):
+		log.Printf("could not claim transaction: %s\n", err)
+		return false
+	case errors.Is(err, repository.ErrExecutionRetry):
+		log.Printf("transaction %s will be retried: %s\n", transaction.Id.String(), err)
+	case err != nil:
+		log.Printf("transaction %s failed: %s\n", transaction.Id.String(), err)
+		wp.broker.Publish(transaction.Id, transaction.State)
+	default:
+		wp.broker.Publish(transaction.Id, transaction.State)
+	}
+	return true
+}
+
+// processBatch executes the next queued batch, it returns false if there was
+// nothing to do.
+func (wp *WorkerPool) processBatch() bool {
+	batch, err := wp.bchRepo.ProcessBatch(context.Background())
+	switch {
+	case errors.Is(err, repository.ErrQueueEmpty):
+		return false
+	case err != nil && (batch.Id == uuid.UUID{}):
+		log.Printf("could not claim batch: %s\n", err)
+		return false
+	case errors.Is(err, repository.ErrExecutionRetry):
+		log.Printf("batch %s will be retried: %s\n", batch.Id.String(), err)
+	case err != nil:
+		log.Printf("batch %s failed: %s\n", batch.Id.String(), err)
+		wp.publishLegs(batch)
+	default:
+		wp.publishLegs(batch)
+	}
+	return true
+}
+
+func (wp *WorkerPool) publishLegs(batch model.Batch) {
+	for _, leg := range batch.Legs {
+		wp.broker.Publish(leg.Id, leg.State)
+	}
+}
+
+// wait blocks until the pool is notified of new work or the poll interval
+// elapses, it returns false if the pool was stopped.
+func (wp *WorkerPool) wait() bool {
+	select {
+	case <-wp.done:
+		return false
+	case <-wp.wake:
+		return true
+	case <-time.After(wp.poll):
+		return true
+	}
+}
+
+// Notify wakes up an idle worker, queued transactions are picked up on the
+// next poll even if nobody calls this.
+func (wp *WorkerPool) Notify() {
+	select {
+	case wp.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (wp *WorkerPool) Stop() {
+	close(wp.done)
+}
+
 func NewWorkerPool(
 	workers int,
-	jobQueue <-chan model.Transaction,
+	poll time.Duration,
 	repo repository.TransactionsRepository,
+	bchRepo repository.BatchesRepository,
+	broker *Broker,
 ) WorkerPool {
 	if workers < 1 {
 		panic("number of workers must be >= 1")
 	}
 
 	wp := WorkerPool{
-		jobQueue: jobQueue,
-		workers:  workers,
-		repo:     repo,
+		repo:    repo,
+		bchRepo: bchRepo,
+		broker:  broker,
+		workers: workers,
+		poll:    poll,
+		wake:    make(chan struct{}, workers),
+		done:    make(chan struct{}),
 	}
 
 	for i := 0; i < workers; i++ {
@@ -21,8 +21,9 @@ func main() {
 
 	authRepo := repository.NewAuthRepository(db)
 	ownRepo := repository.NewOwnershipRepository(db)
+	idmRepo := repository.NewIdmRepository(db)
 
-	mdf := middleware.NewMiddlewareFactory(ownRepo)
+	mdf := middleware.NewMiddlewareFactory(ownRepo, idmRepo)
 	authf := NewAuthHandlerFactory(authRepo, mdf)
 
 	http.Handle("POST /auth", authf.Authenticate())
@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type CreateBatchRequestDTO struct {
+	Legs []CreateTransactionRequestDTO `json:"legs"`
+}
+
+func (data *CreateBatchRequestDTO) Parse() (model.Batch, error) {
+	legs := make([]model.Transaction, 0, len(data.Legs))
+	for _, legData := range data.Legs {
+		leg, err := legData.Parse()
+		if err != nil {
+			return model.Batch{}, err
+		}
+		legs = append(legs, leg)
+	}
+
+	batch, err := model.NewBatch(legs)
+	if err != nil {
+		return model.Batch{}, err
+	}
+
+	return batch, nil
+}
+
+type CreateBatchResponseDTO struct {
+	Id string `json:"id"`
+}
+
+type ReadBatchLegResponseDTO struct {
+	Id            string `json:"id"`
+	State         string `json:"state"`
+	Currency      string `json:"currency"`
+	Amount        string `json:"amount"`
+	Source        string `json:"source"`
+	Destination   string `json:"destination"`
+	FailureCode   string `json:"failure_code,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+type ReadBatchResponseDTO struct {
+	Id            string                    `json:"id"`
+	State         string                    `json:"state"`
+	Time          string                    `json:"time"`
+	FailureCode   string                    `json:"failure_code,omitempty"`
+	FailureReason string                    `json:"failure_reason,omitempty"`
+	Legs          []ReadBatchLegResponseDTO `json:"legs"`
+}
+
+func NewReadBatchResponseDTO(batch model.Batch) ReadBatchResponseDTO {
+	res := ReadBatchResponseDTO{
+		Id:            batch.Id.String(),
+		State:         batch.State,
+		Time:          batch.Time,
+		FailureCode:   batch.FailureCode,
+		FailureReason: batch.FailureReason,
+		Legs:          make([]ReadBatchLegResponseDTO, 0, len(batch.Legs)),
+	}
+
+	for _, leg := range batch.Legs {
+		res.Legs = append(res.Legs, ReadBatchLegResponseDTO{
+			Id:            leg.Id.String(),
+			State:         leg.State,
+			Currency:      leg.Currency,
+			Amount:        leg.Amount.String(),
+			Source:        leg.Source.String(),
+			Destination:   leg.Destination.String(),
+			FailureCode:   leg.FailureCode,
+			FailureReason: leg.FailureReason,
+		})
+	}
+
+	return res
+}
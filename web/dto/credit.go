@@ -0,0 +1,108 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// UpdateCreditLineRequestDTO sets the terms of a line of credit, rates are
+// percentages.
+type UpdateCreditLineRequestDTO struct {
+	Limit         string `json:"limit"`
+	Rate          string `json:"rate"`
+	MinimumRate   string `json:"minimum_rate"`
+	MinimumAmount string `json:"minimum_amount"`
+	GraceDays     int    `json:"grace_days"`
+}
+
+func (data *UpdateCreditLineRequestDTO) Parse(service uuid.UUID) (model.CreditLine, error) {
+	limit, err := decimal.NewFromString(data.Limit)
+	if err != nil {
+		return model.CreditLine{}, err
+	}
+
+	rate, err := decimal.NewFromString(data.Rate)
+	if err != nil {
+		return model.CreditLine{}, err
+	}
+
+	minimumRate, err := decimal.NewFromString(data.MinimumRate)
+	if err != nil {
+		return model.CreditLine{}, err
+	}
+
+	minimumAmount, err := decimal.NewFromString(data.MinimumAmount)
+	if err != nil {
+		return model.CreditLine{}, err
+	}
+
+	return model.NewCreditLine(service, limit, rate, minimumRate, minimumAmount, data.GraceDays)
+}
+
+type ReadBillingCycleResponseDTO struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ReadStatementResponseDTO shows the last statement, payments are the ones
+// received between the end of its cycle and its due date.
+type ReadStatementResponseDTO struct {
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Due        string `json:"due"`
+	Statement  string `json:"statement"`
+	MinimumDue string `json:"minimum_due"`
+	Interest   string `json:"interest"`
+	Payments   string `json:"payments"`
+	Remaining  string `json:"remaining"`
+	LateFee    string `json:"late_fee"`
+	Late       bool   `json:"late"`
+}
+
+type ReadBillingResponseDTO struct {
+	Limit         string                      `json:"limit"`
+	Rate          string                      `json:"rate"`
+	MinimumRate   string                      `json:"minimum_rate"`
+	MinimumAmount string                      `json:"minimum_amount"`
+	GraceDays     int                         `json:"grace_days"`
+	Owed          string                      `json:"owed"`
+	Available     string                      `json:"available"`
+	Current       ReadBillingCycleResponseDTO `json:"current"`
+	Statement     *ReadStatementResponseDTO   `json:"statement,omitempty"`
+}
+
+func NewReadBillingResponseDTO(billing model.Billing) ReadBillingResponseDTO {
+	res := ReadBillingResponseDTO{
+		Limit:         billing.Line.Limit.StringFixed(2),
+		Rate:          billing.Line.Rate.String(),
+		MinimumRate:   billing.Line.MinimumRate.String(),
+		MinimumAmount: billing.Line.MinimumAmount.StringFixed(2),
+		GraceDays:     billing.Line.GraceDays,
+		Owed:          billing.Owed.StringFixed(2),
+		Available:     billing.Available.StringFixed(2),
+		Current: ReadBillingCycleResponseDTO{
+			Start: billing.Current.Start.Format(time.DateOnly),
+			End:   billing.Current.End.Format(time.DateOnly),
+		},
+	}
+
+	if statement := billing.Statement; (statement.Id != uuid.UUID{}) {
+		res.Statement = &ReadStatementResponseDTO{
+			Start:      statement.Start.Format(time.DateOnly),
+			End:        statement.End.Format(time.DateOnly),
+			Due:        statement.Due.Format(time.DateOnly),
+			Statement:  statement.Statement.StringFixed(2),
+			MinimumDue: statement.MinimumDue.StringFixed(2),
+			Interest:   statement.Interest.StringFixed(2),
+			Payments:   statement.Payments.StringFixed(2),
+			Remaining:  statement.Remaining().StringFixed(2),
+			LateFee:    statement.LateFee.StringFixed(2),
+			Late:       statement.Late,
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,88 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+// UpdateDepositRequestDTO starts the term of a deposit, term is in months and
+// payout is only set when the instruction is PAY.
+type UpdateDepositRequestDTO struct {
+	Term        int    `json:"term"`
+	Instruction string `json:"instruction"`
+	Payout      string `json:"payout"`
+}
+
+func (data *UpdateDepositRequestDTO) Parse(service uuid.UUID) (model.Deposit, error) {
+	payout, err := parsePayout(data.Payout)
+	if err != nil {
+		return model.Deposit{}, err
+	}
+
+	return model.NewDeposit(service, data.Term, data.Instruction, payout)
+}
+
+type UpdateDepositInstructionRequestDTO struct {
+	Instruction string `json:"instruction"`
+	Payout      string `json:"payout"`
+}
+
+func (data *UpdateDepositInstructionRequestDTO) Parse(
+	service uuid.UUID,
+) (model.Deposit, error) {
+	payout, err := parsePayout(data.Payout)
+	if err != nil {
+		return model.Deposit{}, err
+	}
+
+	deposit := model.Deposit{
+		Service:     service,
+		Instruction: data.Instruction,
+		Payout:      payout,
+	}
+	if err := deposit.ValidateInstruction(); err != nil {
+		return model.Deposit{}, err
+	}
+
+	return deposit, nil
+}
+
+func parsePayout(payout string) (uuid.NullUUID, error) {
+	if payout == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	id, err := uuid.Parse(payout)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+type ReadDepositResponseDTO struct {
+	Term        int    `json:"term"`
+	Start       string `json:"start"`
+	Maturity    string `json:"maturity"`
+	Instruction string `json:"instruction"`
+	Payout      string `json:"payout,omitempty"`
+	State       string `json:"state"`
+}
+
+func NewReadDepositResponseDTO(deposit model.Deposit) ReadDepositResponseDTO {
+	res := ReadDepositResponseDTO{
+		Term:        deposit.Term,
+		Start:       deposit.Start.Format(time.DateOnly),
+		Maturity:    deposit.Maturity.Format(time.DateOnly),
+		Instruction: deposit.Instruction,
+		State:       deposit.State,
+	}
+
+	if deposit.Payout.Valid {
+		res.Payout = deposit.Payout.UUID.String()
+	}
+
+	return res
+}
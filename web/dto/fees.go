@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+// SaveFeeRuleRequestDTO sets the fee of a kind for services of a type and
+// currency, rate is a percentage and an empty one means no rate.
+type SaveFeeRuleRequestDTO struct {
+	ServiceType string `json:"service_type"`
+	Kind        string `json:"kind"`
+	Currency    string `json:"currency"`
+	Amount      string `json:"amount"`
+	Rate        string `json:"rate"`
+}
+
+func (data *SaveFeeRuleRequestDTO) Parse() (model.FeeRule, error) {
+	amount, err := parseOptionalAmount(data.Amount)
+	if err != nil {
+		return model.FeeRule{}, err
+	}
+
+	rate, err := parseOptionalAmount(data.Rate)
+	if err != nil {
+		return model.FeeRule{}, err
+	}
+
+	return model.NewFeeRule(data.ServiceType, data.Kind, data.Currency, amount, rate)
+}
+
+type ReadFeeRuleResponseDTO struct {
+	Id          string `json:"id"`
+	ServiceType string `json:"service_type"`
+	Kind        string `json:"kind"`
+	Currency    string `json:"currency"`
+	Amount      string `json:"amount"`
+	Rate        string `json:"rate"`
+}
+
+func NewReadFeeRuleResponseDTO(rule model.FeeRule) ReadFeeRuleResponseDTO {
+	return ReadFeeRuleResponseDTO{
+		Id:          rule.Id.String(),
+		ServiceType: rule.ServiceType,
+		Kind:        rule.Kind,
+		Currency:    rule.Currency,
+		Amount:      rule.Amount.String(),
+		Rate:        rule.Rate.String(),
+	}
+}
@@ -0,0 +1,122 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+const ecbBase = "EUR"
+
+// ECBRatesDTO is the envelope published by the European Central Bank with the
+// euro foreign exchange reference rates, one cube per day.
+type ECBRatesDTO struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Days    []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+func (data *ECBRatesDTO) Parse() ([]model.ExchangeRate, error) {
+	rates := make([]model.ExchangeRate, 0)
+	for _, day := range data.Days {
+		for _, entry := range day.Rates {
+			rate, err := decimal.NewFromString(entry.Rate)
+			if err != nil {
+				return nil, err
+			}
+
+			exchangeRate := model.ExchangeRate{
+				Base:     ecbBase,
+				Currency: entry.Currency,
+				Rate:     rate,
+				Time:     day.Time,
+			}
+			if err := exchangeRate.Validate(); err != nil {
+				return nil, err
+			}
+			rates = append(rates, exchangeRate)
+		}
+	}
+
+	if len(rates) == 0 {
+		return nil, errors.New("no exchange rates found")
+	}
+
+	return rates, nil
+}
+
+// ParseRatesCSV reads exchange rates from a CSV file with the header
+// base,currency,rate,time where the time column is optional.
+func ParseRatesCSV(r io.Reader) ([]model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) < 2 {
+		return nil, errors.New("no exchange rates found")
+	}
+
+	rates := make([]model.ExchangeRate, 0, len(records)-1)
+	for i, record := range records[1:] {
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: expected 3 or 4 fields", i+2)
+		}
+
+		rate, err := decimal.NewFromString(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+
+		exchangeRate := model.ExchangeRate{
+			Base:     strings.ToUpper(record[0]),
+			Currency: strings.ToUpper(record[1]),
+			Rate:     rate,
+			Time:     "NOW",
+		}
+		if len(record) == 4 && record[3] != "" {
+			exchangeRate.Time = record[3]
+		}
+
+		if err := exchangeRate.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		rates = append(rates, exchangeRate)
+	}
+
+	return rates, nil
+}
+
+type ReadRateResponseDTO struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Rate   string `json:"rate"`
+}
+
+type CreateQuoteRequestDTO struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type ReadQuoteResponseDTO struct {
+	Id      string `json:"id"`
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Rate    string `json:"rate"`
+	Expires string `json:"expires"`
+}
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type CreateHoldRequestDTO struct {
+	Amount  string `json:"amount"`
+	Expires string `json:"expires"`
+}
+
+func (data *CreateHoldRequestDTO) Parse(service uuid.UUID) (model.Hold, error) {
+	amount, err := decimal.NewFromString(data.Amount)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	expires, err := time.Parse(time.RFC3339, data.Expires)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	hold, err := model.NewHold(service, amount, expires)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+type CreateHoldResponseDTO struct {
+	Id string `json:"id"`
+}
+
+type ReadHoldResponseDTO struct {
+	Id       string `json:"id"`
+	Service  string `json:"service"`
+	Amount   string `json:"amount"`
+	Captured string `json:"captured"`
+	State    string `json:"state"`
+	Time     string `json:"time"`
+	Expires  string `json:"expires"`
+}
+
+func NewReadHoldResponseDTO(hold model.Hold) ReadHoldResponseDTO {
+	state := hold.State
+	if state == model.HoldStateActive && !hold.Active() {
+		state = model.HoldStateExpired
+	}
+
+	return ReadHoldResponseDTO{
+		Id:       hold.Id.String(),
+		Service:  hold.Service.String(),
+		Amount:   hold.Amount.String(),
+		Captured: hold.Captured.String(),
+		State:    state,
+		Time:     hold.Time,
+		Expires:  hold.Expires.Format(time.RFC3339),
+	}
+}
+
+type CaptureHoldRequestDTO struct {
+	Amount      string `json:"amount"`
+	Destination string `json:"destination"`
+}
+
+func (data *CaptureHoldRequestDTO) Parse() (decimal.Decimal, uuid.UUID, error) {
+	amount, err := decimal.NewFromString(data.Amount)
+	if err != nil {
+		return decimal.Decimal{}, uuid.UUID{}, err
+	}
+
+	dst, err := uuid.Parse(data.Destination)
+	if err != nil {
+		return decimal.Decimal{}, uuid.UUID{}, err
+	}
+
+	return amount, dst, nil
+}
@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// UpdateInterestRequestDTO sets the yearly rate of a service as a percentage,
+// the day count convention defaults to ACT/365.
+type UpdateInterestRequestDTO struct {
+	Rate     string `json:"rate"`
+	DayCount string `json:"day_count"`
+}
+
+func (data *UpdateInterestRequestDTO) Parse(service uuid.UUID) (model.Interest, error) {
+	rate, err := decimal.NewFromString(data.Rate)
+	if err != nil {
+		return model.Interest{}, err
+	}
+
+	return model.NewInterest(service, rate, data.DayCount)
+}
+
+// ReadInterestResponseDTO shows the interest accrued up to the end of
+// accrued_through that will be paid on next_capitalization.
+type ReadInterestResponseDTO struct {
+	Rate               string `json:"rate"`
+	DayCount           string `json:"day_count"`
+	Accrued            string `json:"accrued"`
+	AccruedThrough     string `json:"accrued_through"`
+	NextCapitalization string `json:"next_capitalization"`
+}
+
+func NewReadInterestResponseDTO(interest model.Interest) ReadInterestResponseDTO {
+	return ReadInterestResponseDTO{
+		Rate:               interest.Rate.String(),
+		DayCount:           interest.DayCount,
+		Accrued:            interest.Accrued.Truncate(2).StringFixed(2),
+		AccruedThrough:     interest.AccruedThrough.Format(time.DateOnly),
+		NextCapitalization: interest.NextCapitalization().Format(time.DateOnly),
+	}
+}
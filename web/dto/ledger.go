@@ -0,0 +1,19 @@
+package dto
+
+type ReadPostingResponseDTO struct {
+	Id          string `json:"id"`
+	Entry       string `json:"entry"`
+	Transaction string `json:"transaction"`
+	Time        string `json:"time"`
+	Currency    string `json:"currency"`
+	Amount      string `json:"amount"`
+	Balance     string `json:"balance"`
+}
+
+type ReadReconciliationResponseDTO struct {
+	Service  string `json:"service"`
+	Balance  string `json:"balance"`
+	Ledger   string `json:"ledger"`
+	Postings int64  `json:"postings"`
+	Balanced bool   `json:"balanced"`
+}
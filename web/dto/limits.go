@@ -0,0 +1,87 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// UpdateLimitsRequestDTO replaces every limit of a service, omitted or zero
+// values remove the limit.
+type UpdateLimitsRequestDTO struct {
+	MaxAmount     string `json:"max_amount"`
+	DailyAmount   string `json:"daily_amount"`
+	MonthlyAmount string `json:"monthly_amount"`
+	DailyCount    int    `json:"daily_count"`
+}
+
+func parseOptionalAmount(amount string) (decimal.Decimal, error) {
+	if amount == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(amount)
+}
+
+func (data *UpdateLimitsRequestDTO) Parse(service uuid.UUID) (model.Limits, error) {
+	limits := model.Limits{
+		Service:    service,
+		DailyCount: data.DailyCount,
+	}
+
+	var err error
+	if limits.MaxAmount, err = parseOptionalAmount(data.MaxAmount); err != nil {
+		return model.Limits{}, err
+	}
+	if limits.DailyAmount, err = parseOptionalAmount(data.DailyAmount); err != nil {
+		return model.Limits{}, err
+	}
+	if limits.MonthlyAmount, err = parseOptionalAmount(data.MonthlyAmount); err != nil {
+		return model.Limits{}, err
+	}
+
+	if err := limits.Validate(); err != nil {
+		return model.Limits{}, err
+	}
+
+	return limits, nil
+}
+
+type ReadAllowanceResponseDTO struct {
+	MaxAmount     string `json:"max_amount,omitempty"`
+	DailyAmount   string `json:"daily_amount,omitempty"`
+	MonthlyAmount string `json:"monthly_amount,omitempty"`
+	DailyCount    *int   `json:"daily_count,omitempty"`
+}
+
+type ReadLimitsResponseDTO struct {
+	MaxAmount     string                   `json:"max_amount"`
+	DailyAmount   string                   `json:"daily_amount"`
+	MonthlyAmount string                   `json:"monthly_amount"`
+	DailyCount    int                      `json:"daily_count"`
+	Remaining     ReadAllowanceResponseDTO `json:"remaining"`
+}
+
+func NewReadLimitsResponseDTO(
+	limits model.Limits,
+	allowance model.Allowance,
+) ReadLimitsResponseDTO {
+	res := ReadLimitsResponseDTO{
+		MaxAmount:     limits.MaxAmount.String(),
+		DailyAmount:   limits.DailyAmount.String(),
+		MonthlyAmount: limits.MonthlyAmount.String(),
+		DailyCount:    limits.DailyCount,
+	}
+
+	if allowance.MaxAmount != nil {
+		res.Remaining.MaxAmount = allowance.MaxAmount.String()
+	}
+	if allowance.DailyAmount != nil {
+		res.Remaining.DailyAmount = allowance.DailyAmount.String()
+	}
+	if allowance.MonthlyAmount != nil {
+		res.Remaining.MonthlyAmount = allowance.MonthlyAmount.String()
+	}
+	res.Remaining.DailyCount = allowance.DailyCount
+
+	return res
+}
@@ -0,0 +1,107 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// UpdateLoanRequestDTO sets the terms of a loan, rate is a yearly percentage
+// and term the number of installments.
+type UpdateLoanRequestDTO struct {
+	Principal    string `json:"principal"`
+	Rate         string `json:"rate"`
+	Term         int    `json:"term"`
+	Frequency    string `json:"frequency"`
+	Disbursement string `json:"disbursement"`
+}
+
+func (data *UpdateLoanRequestDTO) Parse(service uuid.UUID) (model.Loan, error) {
+	principal, err := decimal.NewFromString(data.Principal)
+	if err != nil {
+		return model.Loan{}, err
+	}
+
+	rate, err := decimal.NewFromString(data.Rate)
+	if err != nil {
+		return model.Loan{}, err
+	}
+
+	disbursement, err := uuid.Parse(data.Disbursement)
+	if err != nil {
+		return model.Loan{}, err
+	}
+
+	return model.NewLoan(service, principal, rate, data.Term, data.Frequency, disbursement)
+}
+
+type ReadInstallmentResponseDTO struct {
+	Number        int    `json:"number"`
+	Due           string `json:"due"`
+	Interest      string `json:"interest"`
+	Principal     string `json:"principal"`
+	LateFee       string `json:"late_fee"`
+	PaidInterest  string `json:"paid_interest"`
+	PaidPrincipal string `json:"paid_principal"`
+	PaidLateFee   string `json:"paid_late_fee"`
+	Late          bool   `json:"late"`
+	State         string `json:"state"`
+}
+
+// ReadLoanResponseDTO has the schedule of the loan once it is originated,
+// outstanding, arrears and payoff are quoted for today.
+type ReadLoanResponseDTO struct {
+	Principal    string                       `json:"principal"`
+	Rate         string                       `json:"rate"`
+	Term         int                          `json:"term"`
+	Frequency    string                       `json:"frequency"`
+	Disbursement string                       `json:"disbursement"`
+	Originated   string                       `json:"originated,omitempty"`
+	Outstanding  string                       `json:"outstanding"`
+	Arrears      string                       `json:"arrears"`
+	Payoff       string                       `json:"payoff"`
+	Installments []ReadInstallmentResponseDTO `json:"installments"`
+}
+
+func NewReadLoanResponseDTO(loan model.Loan) ReadLoanResponseDTO {
+	res := ReadLoanResponseDTO{
+		Principal:    loan.Principal.String(),
+		Rate:         loan.Rate.String(),
+		Term:         loan.Term,
+		Frequency:    loan.Frequency,
+		Disbursement: loan.Disbursement.String(),
+		Installments: make([]ReadInstallmentResponseDTO, 0, len(loan.Installments)),
+	}
+
+	if loan.Originated.IsZero() {
+		res.Outstanding = loan.Principal.String()
+		res.Arrears = decimal.Zero.String()
+		res.Payoff = loan.Principal.String()
+		return res
+	}
+
+	quote := loan.Quote(model.Today())
+	res.Originated = loan.Originated.Format(time.DateOnly)
+	res.Outstanding = quote.Outstanding.String()
+	res.Arrears = quote.Arrears.String()
+	res.Payoff = quote.Payoff.String()
+
+	for _, installment := range loan.Installments {
+		res.Installments = append(res.Installments, ReadInstallmentResponseDTO{
+			Number:        installment.Number,
+			Due:           installment.Due.Format(time.DateOnly),
+			Interest:      installment.Interest.String(),
+			Principal:     installment.Principal.String(),
+			LateFee:       installment.LateFee.String(),
+			PaidInterest:  installment.PaidInterest.String(),
+			PaidPrincipal: installment.PaidPrincipal.String(),
+			PaidLateFee:   installment.PaidLateFee.String(),
+			Late:          installment.Late,
+			State:         installment.State,
+		})
+	}
+
+	return res
+}
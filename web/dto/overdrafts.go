@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// UpdateOverdraftRequestDTO grants an overdraft or changes its terms, rate is
+// the yearly percentage charged on the overdrawn balance and the NSF policy
+// defaults to REJ.
+type UpdateOverdraftRequestDTO struct {
+	Limit     string `json:"limit"`
+	Rate      string `json:"rate"`
+	NSFPolicy string `json:"nsf_policy"`
+}
+
+func (data *UpdateOverdraftRequestDTO) Parse(service uuid.UUID) (model.Overdraft, error) {
+	limit, err := decimal.NewFromString(data.Limit)
+	if err != nil {
+		return model.Overdraft{}, err
+	}
+
+	rate, err := decimal.NewFromString(data.Rate)
+	if err != nil {
+		return model.Overdraft{}, err
+	}
+
+	return model.NewOverdraft(service, limit, rate, data.NSFPolicy)
+}
+
+// ReadOverdraftResponseDTO shows the interest accrued up to the end of
+// accrued_through that will be charged on next_charge.
+type ReadOverdraftResponseDTO struct {
+	Limit          string `json:"limit"`
+	Rate           string `json:"rate"`
+	NSFPolicy      string `json:"nsf_policy"`
+	Accrued        string `json:"accrued"`
+	AccruedThrough string `json:"accrued_through"`
+	NextCharge     string `json:"next_charge"`
+}
+
+func NewReadOverdraftResponseDTO(overdraft model.Overdraft) ReadOverdraftResponseDTO {
+	return ReadOverdraftResponseDTO{
+		Limit:          overdraft.Limit.StringFixed(2),
+		Rate:           overdraft.Interest.Rate.String(),
+		NSFPolicy:      overdraft.NSFPolicy,
+		Accrued:        overdraft.Interest.Accrued.Truncate(2).StringFixed(2),
+		AccruedThrough: overdraft.Interest.AccruedThrough.Format(time.DateOnly),
+		NextCharge:     overdraft.Interest.NextCapitalization().Format(time.DateOnly),
+	}
+}
@@ -0,0 +1,242 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// Pain001DTO is an ISO 20022 customer credit transfer initiation, accounts
+// are the ids of the services in the Othr identification.
+type Pain001DTO struct {
+	XMLName   xml.Name `xml:"Document"`
+	MessageId string   `xml:"CstmrCdtTrfInitn>GrpHdr>MsgId"`
+	Payments  []struct {
+		Id        string `xml:"PmtInfId"`
+		Debtor    string `xml:"DbtrAcct>Id>Othr>Id"`
+		Transfers []struct {
+			InstructionId string `xml:"PmtId>InstrId"`
+			EndToEndId    string `xml:"PmtId>EndToEndId"`
+			Amount        struct {
+				Currency string `xml:"Ccy,attr"`
+				Value    string `xml:",chardata"`
+			} `xml:"Amt>InstdAmt"`
+			Creditor string `xml:"CdtrAcct>Id>Othr>Id"`
+		} `xml:"CdtTrfTxInf"`
+	} `xml:"CstmrCdtTrfInitn>PmtInf"`
+}
+
+func (data *Pain001DTO) Parse() (model.PaymentImport, error) {
+	payments := model.PaymentImport{MessageId: data.MessageId}
+	for _, payment := range data.Payments {
+		for _, transfer := range payment.Transfers {
+			payments.Instructions = append(payments.Instructions, newPaymentInstruction(
+				payment.Id,
+				transfer.InstructionId,
+				transfer.EndToEndId,
+				payment.Debtor,
+				transfer.Creditor,
+				transfer.Amount.Currency,
+				transfer.Amount.Value))
+		}
+	}
+
+	if err := validatePaymentImport(payments); err != nil {
+		return model.PaymentImport{}, err
+	}
+
+	return payments, nil
+}
+
+// ParsePaymentsCSV reads credit transfers from a CSV file with the header
+// instruction,source,destination,currency,amount.
+func ParsePaymentsCSV(r io.Reader) (model.PaymentImport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 5
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return model.PaymentImport{}, err
+	}
+
+	if len(records) < 2 {
+		return model.PaymentImport{}, errors.New("no payments found")
+	}
+
+	payments := model.PaymentImport{}
+	for _, record := range records[1:] {
+		payments.Instructions = append(payments.Instructions, newPaymentInstruction(
+			"", record[0], record[0], record[1], record[2], record[3], record[4]))
+	}
+
+	if err := validatePaymentImport(payments); err != nil {
+		return model.PaymentImport{}, err
+	}
+
+	return payments, nil
+}
+
+func validatePaymentImport(payments model.PaymentImport) error {
+	if len(payments.Instructions) == 0 {
+		return errors.New("no payments found")
+	}
+	if len(payments.Instructions) > model.PaymentMaxInstructions {
+		return fmt.Errorf("more than %d payments in a single import",
+			model.PaymentMaxInstructions)
+	}
+	return nil
+}
+
+// newPaymentInstruction parses the fields of a single transfer, a transfer
+// that can't be parsed is rejected instead of failing the whole import.
+func newPaymentInstruction(
+	paymentInfo, instructionId, endToEndId, source, destination, currency, amount string,
+) model.PaymentInstruction {
+	instruction := model.PaymentInstruction{
+		PaymentInfo:   paymentInfo,
+		InstructionId: instructionId,
+		EndToEndId:    endToEndId,
+		Currency:      currency,
+		Status:        model.PaymentStatusPending,
+	}
+
+	var err error
+	if instruction.Source, err = uuid.Parse(source); err != nil {
+		instruction.Reject(model.PaymentReasonInvalidAccount, "invalid debtor account")
+		return instruction
+	}
+
+	if instruction.Destination, err = uuid.Parse(destination); err != nil {
+		instruction.Reject(model.PaymentReasonInvalidAccount, "invalid creditor account")
+		return instruction
+	}
+
+	if instruction.Source == instruction.Destination {
+		instruction.Reject(model.PaymentReasonInvalidAccount,
+			"debtor and creditor accounts are the same")
+		return instruction
+	}
+
+	if instruction.Amount, err = decimal.NewFromString(amount); err != nil {
+		instruction.Reject(model.PaymentReasonInvalidFormat, "invalid amount")
+		return instruction
+	}
+
+	if !instruction.Amount.IsPositive() || instruction.Amount.Exponent() < -2 {
+		instruction.Reject(model.PaymentReasonInvalidAmount,
+			"amount must be positive with at most 2 decimals")
+		return instruction
+	}
+
+	return instruction
+}
+
+type pain002Reason struct {
+	Code string `xml:"Rsn>Cd"`
+	Info string `xml:"AddtlInf"`
+}
+
+type pain002Transaction struct {
+	InstructionId string         `xml:"OrgnlInstrId,omitempty"`
+	EndToEndId    string         `xml:"OrgnlEndToEndId,omitempty"`
+	Status        string         `xml:"TxSts"`
+	Reason        *pain002Reason `xml:"StsRsnInf,omitempty"`
+	Reference     string         `xml:"AcctSvcrRef,omitempty"`
+}
+
+type pain002Payment struct {
+	Id           string               `xml:"OrgnlPmtInfId"`
+	Transactions []pain002Transaction `xml:"TxInfAndSts"`
+}
+
+type pain002Document struct {
+	XMLName   xml.Name `xml:"urn:iso:std:iso:20022:tech:xsd:pain.002.001.10 Document"`
+	MessageId string   `xml:"CstmrPmtStsRpt>GrpHdr>MsgId"`
+	Created   string   `xml:"CstmrPmtStsRpt>GrpHdr>CreDtTm"`
+	Original  struct {
+		MessageId   string `xml:"OrgnlMsgId"`
+		MessageName string `xml:"OrgnlMsgNmId"`
+		Count       int    `xml:"OrgnlNbOfTxs"`
+		Status      string `xml:"GrpSts"`
+	} `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts"`
+	Payments []pain002Payment `xml:"CstmrPmtStsRpt>OrgnlPmtInfAndSts"`
+}
+
+// WritePain002 writes the status of every instruction of an import as an
+// ISO 20022 payment status report, accepted instructions reference the
+// transaction that was created.
+func WritePain002(w io.Writer, payments model.PaymentImport) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	var doc pain002Document
+	doc.MessageId = id.String()
+	doc.Created = time.Now().UTC().Format(time.RFC3339)
+	doc.Original.MessageId = payments.MessageId
+	doc.Original.MessageName = "pain.001.001.09"
+	doc.Original.Count = len(payments.Instructions)
+	doc.Original.Status = payments.Status()
+
+	for _, instruction := range payments.Instructions {
+		if len(doc.Payments) == 0 ||
+			doc.Payments[len(doc.Payments)-1].Id != instruction.PaymentInfo {
+			doc.Payments = append(doc.Payments, pain002Payment{Id: instruction.PaymentInfo})
+		}
+		payment := &doc.Payments[len(doc.Payments)-1]
+
+		transaction := pain002Transaction{
+			InstructionId: instruction.InstructionId,
+			EndToEndId:    instruction.EndToEndId,
+			Status:        instruction.Status,
+		}
+		if instruction.Status == model.PaymentStatusRejected {
+			transaction.Reason = &pain002Reason{instruction.Reason, instruction.Info}
+		}
+		if instruction.Transaction.Valid {
+			transaction.Reference = instruction.Transaction.UUID.String()
+		}
+		payment.Transactions = append(payment.Transactions, transaction)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// WritePaymentsCSV writes the status of every instruction of an import with
+// the header instruction,status,reason,info,transaction.
+func WritePaymentsCSV(w io.Writer, payments model.PaymentImport) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{{"instruction", "status", "reason", "info", "transaction"}}
+
+	for _, instruction := range payments.Instructions {
+		transaction := ""
+		if instruction.Transaction.Valid {
+			transaction = instruction.Transaction.UUID.String()
+		}
+
+		records = append(records, []string{
+			instruction.InstructionId,
+			instruction.Status,
+			instruction.Reason,
+			instruction.Info,
+			transaction,
+		})
+	}
+
+	return writer.WriteAll(records)
+}
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type ReadPermissionsResponseDTO struct {
+	Permissions []string `json:"permissions"`
+}
+
+// UpdatePermissionRequestDTO grants or revokes the permission in the path,
+// every change needs a reason.
+type UpdatePermissionRequestDTO struct {
+	Granted bool   `json:"granted"`
+	Reason  string `json:"reason"`
+}
+
+func (data *UpdatePermissionRequestDTO) Parse(
+	service, user uuid.UUID, permission string,
+) (model.PermissionChange, error) {
+	return model.NewPermissionChange(service, uuid.NullUUID{UUID: user, Valid: true},
+		permission, data.Granted, data.Reason)
+}
+
+type ReadPermissionChangeResponseDTO struct {
+	Id         string `json:"id"`
+	User       string `json:"user,omitempty"`
+	Permission string `json:"permission"`
+	Granted    bool   `json:"granted"`
+	Reason     string `json:"reason"`
+	Time       string `json:"time"`
+}
+
+func NewReadPermissionChangeResponseDTO(
+	change model.PermissionChange,
+) ReadPermissionChangeResponseDTO {
+	res := ReadPermissionChangeResponseDTO{
+		Id:         change.Id.String(),
+		Permission: change.Permission,
+		Granted:    change.Granted,
+		Reason:     change.Reason,
+		Time:       change.Time,
+	}
+
+	if change.User.Valid {
+		res.User = change.User.UUID.String()
+	}
+
+	return res
+}
@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+// RiskRulesDTO is the format of the risk rules file, amounts are strings like
+// everywhere else and windows are Go durations such as "1h".
+type RiskRulesDTO struct {
+	Rules []struct {
+		Name      string `json:"name"`
+		Type      string `json:"type"`
+		Action    string `json:"action"`
+		Currency  string `json:"currency"`
+		MinAmount string `json:"min_amount"`
+		Window    string `json:"window"`
+		MaxCount  int    `json:"max_count"`
+		MaxAmount string `json:"max_amount"`
+		FromHour  int    `json:"from_hour"`
+		ToHour    int    `json:"to_hour"`
+		Location  string `json:"location"`
+	} `json:"rules"`
+}
+
+func (data *RiskRulesDTO) Parse() (model.RiskRules, error) {
+	rules := make(model.RiskRules, 0, len(data.Rules))
+	for _, ruleData := range data.Rules {
+		rule := model.RiskRule{
+			Name:     ruleData.Name,
+			Type:     ruleData.Type,
+			Action:   ruleData.Action,
+			Currency: ruleData.Currency,
+			MaxCount: ruleData.MaxCount,
+			FromHour: ruleData.FromHour,
+			ToHour:   ruleData.ToHour,
+		}
+
+		var err error
+		if rule.MinAmount, err = parseOptionalAmount(ruleData.MinAmount); err != nil {
+			return nil, err
+		}
+		if rule.MaxAmount, err = parseOptionalAmount(ruleData.MaxAmount); err != nil {
+			return nil, err
+		}
+
+		if ruleData.Window != "" {
+			if rule.Window, err = time.ParseDuration(ruleData.Window); err != nil {
+				return nil, err
+			}
+		}
+
+		if ruleData.Location != "" {
+			if rule.Location, err = time.LoadLocation(ruleData.Location); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+type ReadReviewResponseDTO struct {
+	Transaction string `json:"transaction"`
+	Rule        string `json:"rule"`
+	Time        string `json:"time"`
+}
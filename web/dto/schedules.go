@@ -0,0 +1,145 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+type CreateScheduleRequestDTO struct {
+	Destination string `json:"destination"`
+	Currency    string `json:"currency"`
+	Amount      string `json:"amount"`
+	Frequency   string `json:"frequency"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Count       int    `json:"count"`
+}
+
+func (data *CreateScheduleRequestDTO) Parse(service uuid.UUID) (model.Schedule, error) {
+	amount, err := decimal.NewFromString(data.Amount)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	dst, err := uuid.Parse(data.Destination)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	start, err := time.Parse(time.RFC3339, data.Start)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	end, err := parseOptionalTime(data.End)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	schedule, err := model.NewSchedule(service, dst, data.Currency, amount,
+		data.Frequency, start, end, data.Count)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+type CreateScheduleResponseDTO struct {
+	Id string `json:"id"`
+}
+
+type ReadScheduleResponseDTO struct {
+	Id          string `json:"id"`
+	Service     string `json:"service"`
+	Destination string `json:"destination"`
+	Currency    string `json:"currency"`
+	Amount      string `json:"amount"`
+	Frequency   string `json:"frequency"`
+	State       string `json:"state"`
+	Start       string `json:"start"`
+	NextRun     string `json:"next_run"`
+	End         string `json:"end,omitempty"`
+	Count       int    `json:"count"`
+	Runs        int    `json:"runs"`
+}
+
+func NewReadScheduleResponseDTO(schedule model.Schedule) ReadScheduleResponseDTO {
+	res := ReadScheduleResponseDTO{
+		Id:          schedule.Id.String(),
+		Service:     schedule.Service.String(),
+		Destination: schedule.Destination.String(),
+		Currency:    schedule.Currency,
+		Amount:      schedule.Amount.String(),
+		Frequency:   schedule.Frequency,
+		State:       schedule.State,
+		Start:       schedule.Start.Format(time.RFC3339),
+		NextRun:     schedule.NextRun.Format(time.RFC3339),
+		Count:       schedule.MaxRuns,
+		Runs:        schedule.Runs,
+	}
+	if !schedule.End.IsZero() {
+		res.End = schedule.End.Format(time.RFC3339)
+	}
+	return res
+}
+
+// UpdateScheduleRequestDTO changes an active schedule, empty fields are left
+// as they are. Schedules left without runs are completed.
+type UpdateScheduleRequestDTO struct {
+	Amount string `json:"amount"`
+	End    string `json:"end"`
+	Count  *int   `json:"count"`
+}
+
+func (data *UpdateScheduleRequestDTO) Apply(schedule *model.Schedule) error {
+	if data.Amount != "" {
+		amount, err := decimal.NewFromString(data.Amount)
+		if err != nil {
+			return err
+		}
+		schedule.Amount = amount
+	}
+
+	if data.End != "" {
+		end, err := parseOptionalTime(data.End)
+		if err != nil {
+			return err
+		}
+		schedule.End = end
+	}
+
+	if data.Count != nil {
+		schedule.MaxRuns = *data.Count
+	}
+
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
+	// a new end or count can leave the schedule without runs
+	if schedule.Exhausted() {
+		schedule.State = model.ScheduleStateCompleted
+	}
+	return nil
+}
+
+type ReadScheduleExecutionResponseDTO struct {
+	Id               string `json:"id"`
+	Time             string `json:"time"`
+	Outcome          string `json:"outcome"`
+	Reason           string `json:"reason,omitempty"`
+	Transaction      string `json:"transaction,omitempty"`
+	TransactionState string `json:"transaction_state,omitempty"`
+	FailureCode      string `json:"failure_code,omitempty"`
+}
+
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"github.com/google/uuid"
 	"github.com/ndfsa/cardboard-bank/common/model"
 	"github.com/shopspring/decimal"
 )
@@ -30,17 +31,79 @@ type CreateServiceResponseDTO struct {
 }
 
 type ReadServiceResponseDTO struct {
-	Id          string `json:"id"`
-	Type        string `json:"type"`
-	State       string `json:"state"`
-	Currency    string `json:"currency"`
-	InitBalance string `json:"init_balance"`
-	Balance     string `json:"balance"`
+	Id          string   `json:"id"`
+	Type        string   `json:"type"`
+	State       string   `json:"state"`
+	Currency    string   `json:"currency"`
+	InitBalance string   `json:"init_balance"`
+	Balance     string   `json:"balance"`
+	Held        string   `json:"held"`
+	Available   string   `json:"available_balance"`
+	Permissions []string `json:"permissions"`
+}
+
+func NewReadServiceResponseDTO(service model.Service) ReadServiceResponseDTO {
+	return ReadServiceResponseDTO{
+		Id:          service.Id.String(),
+		Type:        service.Type,
+		State:       service.State,
+		Currency:    service.Currency,
+		InitBalance: service.InitBalance.String(),
+		Balance:     service.Balance.String(),
+		Held:        service.Held.String(),
+		Available:   service.Available().String(),
+		Permissions: service.PermissionNames(),
+	}
 }
 
+// UpdateServiceRequestDTO moves a service to another state, freezing or
+// closing it needs a reason.
 type UpdateServiceRequestDTO struct {
-	State   string `json:"state"`
-	Balance string `json:"balance"`
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+}
+
+func (data *UpdateServiceRequestDTO) Parse(
+	service, user uuid.UUID,
+) (model.StateChange, error) {
+	return model.NewStateChange(service, uuid.NullUUID{UUID: user, Valid: true},
+		data.State, data.Reason)
+}
+
+type DeleteServiceRequestDTO struct {
+	Reason string `json:"reason"`
+}
+
+func (data *DeleteServiceRequestDTO) Parse(
+	service, user uuid.UUID,
+) (model.StateChange, error) {
+	return model.NewStateChange(service, uuid.NullUUID{UUID: user, Valid: true},
+		model.ServiceStateClosed, data.Reason)
+}
+
+type ReadStateChangeResponseDTO struct {
+	Id     string `json:"id"`
+	User   string `json:"user,omitempty"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+	Time   string `json:"time"`
+}
+
+func NewReadStateChangeResponseDTO(change model.StateChange) ReadStateChangeResponseDTO {
+	res := ReadStateChangeResponseDTO{
+		Id:     change.Id.String(),
+		From:   change.From,
+		To:     change.To,
+		Reason: change.Reason,
+		Time:   change.Time,
+	}
+
+	if change.User.Valid {
+		res.User = change.User.UUID.String()
+	}
+
+	return res
 }
 
 type UpdateUserServiceDTO struct {
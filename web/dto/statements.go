@@ -0,0 +1,320 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+// ParseStatementPeriod reads the period of a statement from the query string,
+// from and to are either dates, to being the last day included, or RFC 3339
+// times, to being excluded.
+func ParseStatementPeriod(query url.Values) (time.Time, time.Time, error) {
+	from, _, err := parseStatementTime(query.Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to, isDate, err := parseStatementTime(query.Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if isDate {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	if err := model.ValidateStatementPeriod(from, to); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return from, to, nil
+}
+
+func parseStatementTime(value string) (time.Time, bool, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, true, nil
+	}
+
+	moment, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid statement date %q", value)
+	}
+	return moment, false, nil
+}
+
+// StatementWriter writes a statement in one of the supported formats.
+type StatementWriter struct {
+	ContentType string
+	Extension   string
+	Write       func(io.Writer, model.Statement) error
+}
+
+var StatementWriters = map[string]StatementWriter{
+	model.StatementFormatCSV:     {"text/csv", "csv", WriteStatementCSV},
+	model.StatementFormatOFX:     {"application/x-ofx", "ofx", WriteStatementOFX},
+	model.StatementFormatQIF:     {"application/qif", "qif", WriteStatementQIF},
+	model.StatementFormatCamt053: {"application/xml", "xml", WriteStatementCamt053},
+}
+
+// WriteStatementCSV writes one row per line between an opening and a closing
+// balance row.
+func WriteStatementCSV(w io.Writer, statement model.Statement) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{
+		{"date", "transaction", "counterparty", "description", "currency", "amount", "balance"},
+		{statement.From.UTC().Format(time.RFC3339), "", "", "Opening balance",
+			statement.Currency, "", statement.Opening.StringFixed(2)},
+	}
+
+	for _, line := range statement.Lines {
+		records = append(records, []string{
+			line.Time.UTC().Format(time.RFC3339),
+			line.Transaction.String(),
+			line.Counterparty.String(),
+			line.Description(),
+			statement.Currency,
+			line.Amount.StringFixed(2),
+			line.Balance.StringFixed(2),
+		})
+	}
+
+	records = append(records, []string{
+		statement.To.UTC().Format(time.RFC3339), "", "", "Closing balance",
+		statement.Currency, "", statement.Closing.StringFixed(2),
+	})
+
+	return writer.WriteAll(records)
+}
+
+const ofxTime = "20060102150405"
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	Id     string `xml:"FITID"`
+	Name   string `xml:"NAME"`
+	Memo   string `xml:"MEMO"`
+}
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Status   ofxStatus `xml:"STATUS"`
+		Server   string    `xml:"DTSERVER"`
+		Language string    `xml:"LANGUAGE"`
+	} `xml:"SIGNONMSGSRSV1>SONRS"`
+	Statement struct {
+		TransactionId string    `xml:"TRNUID"`
+		Status        ofxStatus `xml:"STATUS"`
+		Currency      string    `xml:"STMTRS>CURDEF"`
+		Account       struct {
+			BankId string `xml:"BANKID"`
+			Id     string `xml:"ACCTID"`
+			Type   string `xml:"ACCTTYPE"`
+		} `xml:"STMTRS>BANKACCTFROM"`
+		Start        string           `xml:"STMTRS>BANKTRANLIST>DTSTART"`
+		End          string           `xml:"STMTRS>BANKTRANLIST>DTEND"`
+		Transactions []ofxTransaction `xml:"STMTRS>BANKTRANLIST>STMTTRN"`
+		Balance      string           `xml:"STMTRS>LEDGERBAL>BALAMT"`
+		BalanceTime  string           `xml:"STMTRS>LEDGERBAL>DTASOF"`
+	} `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+// WriteStatementOFX writes an OFX 2.2 bank statement response.
+func WriteStatementOFX(w io.Writer, statement model.Statement) error {
+	var doc ofxDocument
+	doc.SignOn.Status = ofxStatus{0, "INFO"}
+	doc.SignOn.Server = statement.Time.UTC().Format(ofxTime)
+	doc.SignOn.Language = "ENG"
+
+	doc.Statement.TransactionId = "0"
+	doc.Statement.Status = ofxStatus{0, "INFO"}
+	doc.Statement.Currency = statement.Currency
+	doc.Statement.Account.BankId = "CARDBOARD"
+	doc.Statement.Account.Id = statement.Service.String()
+	doc.Statement.Account.Type = "CHECKING"
+	if statement.Type == model.ServiceTypeSavings {
+		doc.Statement.Account.Type = "SAVINGS"
+	}
+	doc.Statement.Start = statement.From.UTC().Format(ofxTime)
+	doc.Statement.End = statement.To.UTC().Format(ofxTime)
+	doc.Statement.Balance = statement.Closing.StringFixed(2)
+	doc.Statement.BalanceTime = statement.To.UTC().Format(ofxTime)
+
+	doc.Statement.Transactions = make([]ofxTransaction, 0, len(statement.Lines))
+	for _, line := range statement.Lines {
+		transactionType := "CREDIT"
+		if line.Amount.IsNegative() {
+			transactionType = "DEBIT"
+		}
+
+		doc.Statement.Transactions = append(doc.Statement.Transactions, ofxTransaction{
+			Type:   transactionType,
+			Posted: line.Time.UTC().Format(ofxTime),
+			Amount: line.Amount.StringFixed(2),
+			Id:     line.Id.String(),
+			Name:   line.Counterparty.String(),
+			Memo:   line.Description(),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header+
+		`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+
+		"\n"); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// WriteStatementQIF writes a QIF bank account, the opening balance is the
+// first record as accounting software expects.
+func WriteStatementQIF(w io.Writer, statement model.Statement) error {
+	const qifDate = "01/02/2006"
+
+	if _, err := fmt.Fprintf(w, "!Type:Bank\nD%s\nT%s\nPOpening Balance\n^\n",
+		statement.From.UTC().Format(qifDate),
+		statement.Opening.StringFixed(2)); err != nil {
+		return err
+	}
+
+	for _, line := range statement.Lines {
+		if _, err := fmt.Fprintf(w, "D%s\nT%s\nP%s\nM%s\nN%s\n^\n",
+			line.Time.UTC().Format(qifDate),
+			line.Amount.StringFixed(2),
+			line.Counterparty.String(),
+			line.Description(),
+			line.Transaction.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>DtTm"`
+}
+
+type camtEntry struct {
+	Reference   string     `xml:"NtryRef"`
+	Amount      camtAmount `xml:"Amt"`
+	Indicator   string     `xml:"CdtDbtInd"`
+	Status      string     `xml:"Sts>Cd"`
+	BookingDate string     `xml:"BookgDt>DtTm"`
+	ValueDate   string     `xml:"ValDt>DtTm"`
+	Domain      string     `xml:"BkTxCd>Domn>Cd"`
+	Family      string     `xml:"BkTxCd>Domn>Fmly>Cd"`
+	SubFamily   string     `xml:"BkTxCd>Domn>Fmly>SubFmlyCd"`
+	Transaction string     `xml:"NtryDtls>TxDtls>Refs>TxId"`
+	Info        string     `xml:"NtryDtls>TxDtls>AddtlTxInf"`
+}
+
+type camtDocument struct {
+	XMLName   xml.Name `xml:"urn:iso:std:iso:20022:tech:xsd:camt.053.001.08 Document"`
+	MessageId string   `xml:"BkToCstmrStmt>GrpHdr>MsgId"`
+	Created   string   `xml:"BkToCstmrStmt>GrpHdr>CreDtTm"`
+	Statement struct {
+		Id       string        `xml:"Id"`
+		Created  string        `xml:"CreDtTm"`
+		From     string        `xml:"FrToDt>FrDtTm"`
+		To       string        `xml:"FrToDt>ToDtTm"`
+		Account  string        `xml:"Acct>Id>Othr>Id"`
+		Currency string        `xml:"Acct>Ccy"`
+		Balances []camtBalance `xml:"Bal"`
+		Entries  []camtEntry   `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+func camtIndicator(amount decimal.Decimal) string {
+	if amount.IsNegative() {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+// WriteStatementCamt053 writes an ISO 20022 camt.053.001.08 bank to customer
+// statement, amounts are absolute with a credit or debit indicator.
+func WriteStatementCamt053(w io.Writer, statement model.Statement) error {
+	created := statement.Time.UTC().Format(time.RFC3339)
+	id := fmt.Sprintf("%s-%s", statement.Service.String()[:8],
+		statement.From.UTC().Format("20060102"))
+
+	var doc camtDocument
+	doc.MessageId = id
+	doc.Created = created
+	doc.Statement.Id = id
+	doc.Statement.Created = created
+	doc.Statement.From = statement.From.UTC().Format(time.RFC3339)
+	doc.Statement.To = statement.To.UTC().Format(time.RFC3339)
+	doc.Statement.Account = statement.Service.String()
+	doc.Statement.Currency = statement.Currency
+	doc.Statement.Balances = []camtBalance{
+		{
+			Type:      "OPBD",
+			Amount:    camtAmount{statement.Currency, statement.Opening.Abs().StringFixed(2)},
+			Indicator: camtIndicator(statement.Opening),
+			Date:      doc.Statement.From,
+		},
+		{
+			Type:      "CLBD",
+			Amount:    camtAmount{statement.Currency, statement.Closing.Abs().StringFixed(2)},
+			Indicator: camtIndicator(statement.Closing),
+			Date:      doc.Statement.To,
+		},
+	}
+
+	doc.Statement.Entries = make([]camtEntry, 0, len(statement.Lines))
+	for _, line := range statement.Lines {
+		// issued or received credit transfer
+		family := "RCDT"
+		if line.Amount.IsNegative() {
+			family = "ICDT"
+		}
+
+		booked := line.Time.UTC().Format(time.RFC3339)
+		doc.Statement.Entries = append(doc.Statement.Entries, camtEntry{
+			Reference:   line.Id.String(),
+			Amount:      camtAmount{statement.Currency, line.Amount.Abs().StringFixed(2)},
+			Indicator:   camtIndicator(line.Amount),
+			Status:      "BOOK",
+			BookingDate: booked,
+			ValueDate:   booked,
+			Domain:      "PMNT",
+			Family:      family,
+			SubFamily:   "BOOK",
+			Transaction: line.Transaction.String(),
+			Info:        line.Description(),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
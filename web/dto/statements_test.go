@@ -0,0 +1,110 @@
+package dto
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ndfsa/cardboard-bank/common/model"
+	"github.com/shopspring/decimal"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var (
+	statementService      = uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057")
+	statementCounterparty = uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8058")
+	statementFrom         = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	statementTo           = time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// postingsStatement has an initial balance that is not part of the postings,
+// it has to show up in the opening, closing and line balances.
+func postingsStatement() model.Statement {
+	statement := model.NewStatement(statementService,
+		model.ServiceTypeSavings, "USD", statementFrom, statementTo,
+		decimal.RequireFromString("100.00"), decimal.RequireFromString("250.50"))
+	statement.Time = statementTo
+
+	statement.AddLine(model.StatementLine{
+		Id:           uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8060"),
+		Transaction:  uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8070"),
+		Time:         time.Date(2024, time.March, 4, 9, 30, 0, 0, time.UTC),
+		Amount:       decimal.RequireFromString("1000"),
+		Balance:      decimal.RequireFromString("1250.50"),
+		Counterparty: statementCounterparty,
+	})
+	statement.AddLine(model.StatementLine{
+		Id:           uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8061"),
+		Transaction:  uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8071"),
+		Time:         time.Date(2024, time.March, 15, 18, 5, 0, 0, time.UTC),
+		Amount:       decimal.RequireFromString("-75.25"),
+		Balance:      decimal.RequireFromString("1175.25"),
+		Counterparty: statementCounterparty,
+	})
+
+	return statement
+}
+
+// overdrawnStatement has no lines and a negative balance carried from before
+// the period.
+func overdrawnStatement() model.Statement {
+	statement := model.NewStatement(statementService,
+		model.ServiceTypeChequing, "CAD", statementFrom, statementTo,
+		decimal.Zero, decimal.RequireFromString("-20"))
+	statement.Time = statementTo
+
+	return statement
+}
+
+func TestStatementBalances(t *testing.T) {
+	statement := postingsStatement()
+
+	if want := "350.5"; statement.Opening.String() != want {
+		t.Errorf("opening balance is %s, want %s", statement.Opening, want)
+	}
+	if want := "1275.25"; statement.Closing.String() != want {
+		t.Errorf("closing balance is %s, want %s", statement.Closing, want)
+	}
+	if want := "1350.5"; statement.Lines[0].Balance.String() != want {
+		t.Errorf("balance of the first line is %s, want %s", statement.Lines[0].Balance, want)
+	}
+}
+
+func TestStatementWriters(t *testing.T) {
+	statements := map[string]model.Statement{
+		"postings":  postingsStatement(),
+		"overdrawn": overdrawnStatement(),
+	}
+
+	for name, statement := range statements {
+		for format, writer := range StatementWriters {
+			t.Run(name+"/"+format, func(t *testing.T) {
+				var got bytes.Buffer
+				if err := writer.Write(&got, statement); err != nil {
+					t.Fatal(err)
+				}
+
+				golden := filepath.Join("testdata", name+"."+format+".golden")
+				if *update {
+					if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got.Bytes(), want) {
+					t.Errorf("statement differs from %s\ngot:\n%s\nwant:\n%s",
+						golden, got.Bytes(), want)
+				}
+			})
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package dto
 
 import (
+	"errors"
+	"net/url"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/ndfsa/cardboard-bank/common/model"
 	"github.com/shopspring/decimal"
 )
 
+// CreateTransactionRequestDTO has to accept the penalty to withdraw from a
+// certificate of deposit before it matures.
 type CreateTransactionRequestDTO struct {
-	Currency    string `json:"currency"`
-	Amount      string `json:"amount"`
-	Source      string `json:"source"`
-	Destination string `json:"destination"`
+	Currency      string `json:"currency"`
+	Amount        string `json:"amount"`
+	Source        string `json:"source"`
+	Destination   string `json:"destination"`
+	Quote         string `json:"quote"`
+	AcceptPenalty bool   `json:"accept_penalty"`
 }
 
 func (data *CreateTransactionRequestDTO) Parse() (model.Transaction, error) {
@@ -34,11 +42,25 @@ func (data *CreateTransactionRequestDTO) Parse() (model.Transaction, error) {
 		return model.Transaction{}, err
 	}
 
+	if data.Quote != "" {
+		quote, err := uuid.Parse(data.Quote)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+		transaction.Quote = uuid.NullUUID{UUID: quote, Valid: true}
+	}
+	transaction.AcceptPenalty = data.AcceptPenalty
+
 	return transaction, nil
 }
 
+// CreateTransactionResponseDTO only has the state of the transaction when the
+// client asked to wait for it.
 type CreateTransactionResponseDTO struct {
-	Id string `json:"id"`
+	Id            string `json:"id"`
+	State         string `json:"state,omitempty"`
+	FailureCode   string `json:"failure_code,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
 }
 
 type ReadTransactionResponseDTO struct {
@@ -49,4 +71,92 @@ type ReadTransactionResponseDTO struct {
 	Amount      string
 	Source      string
 	Destination string
+
+	Quote         string `json:",omitempty"`
+	Rate          string
+	SettledAmount string
+
+	Reverses  string   `json:",omitempty"`
+	Reversals []string `json:",omitempty"`
+	Hold      string   `json:",omitempty"`
+
+	Fee     string   `json:",omitempty"`
+	Charges string   `json:",omitempty"`
+	Fees    []string `json:",omitempty"`
+
+	AcceptPenalty bool   `json:",omitempty"`
+	Overdraft     string `json:",omitempty"`
+
+	FailureCode   string `json:",omitempty"`
+	FailureReason string `json:",omitempty"`
+}
+
+// CreateReversalRequestDTO reverses the original transaction, an empty amount
+// reverses everything that was not reversed yet.
+type CreateReversalRequestDTO struct {
+	Amount string `json:"amount"`
+}
+
+func (data *CreateReversalRequestDTO) Parse() (decimal.Decimal, error) {
+	if data.Amount == "" {
+		return decimal.Zero, nil
+	}
+
+	amount, err := decimal.NewFromString(data.Amount)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, errors.New("reversal amount must be positive")
+	}
+	return amount, nil
+}
+
+// ParseTransactionFilter reads the filter of a transaction listing from the
+// query string, dates are RFC 3339 and the range they give is half open.
+func ParseTransactionFilter(query url.Values) (model.TransactionFilter, error) {
+	filter := model.TransactionFilter{
+		Direction: query.Get("direction"),
+		State:     query.Get("state"),
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return model.TransactionFilter{}, err
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return model.TransactionFilter{}, err
+		}
+	}
+
+	if minAmount := query.Get("min_amount"); minAmount != "" {
+		if filter.MinAmount.Decimal, err = decimal.NewFromString(minAmount); err != nil {
+			return model.TransactionFilter{}, err
+		}
+		filter.MinAmount.Valid = true
+	}
+
+	if maxAmount := query.Get("max_amount"); maxAmount != "" {
+		if filter.MaxAmount.Decimal, err = decimal.NewFromString(maxAmount); err != nil {
+			return model.TransactionFilter{}, err
+		}
+		filter.MaxAmount.Valid = true
+	}
+
+	if counterparty := query.Get("counterparty"); counterparty != "" {
+		if filter.Counterparty.UUID, err = uuid.Parse(counterparty); err != nil {
+			return model.TransactionFilter{}, err
+		}
+		filter.Counterparty.Valid = true
+	}
+
+	if err := filter.Validate(); err != nil {
+		return model.TransactionFilter{}, err
+	}
+
+	return filter, nil
 }
@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/ndfsa/cardboard-bank/common/model"
+)
+
+type CreateWebhookRequestDTO struct {
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (data *CreateWebhookRequestDTO) Parse(user model.User) (model.Webhook, error) {
+	return model.NewWebhook(user.Id, user.Clearance >= model.UserClearanceTeller,
+		data.Url, data.Events)
+}
+
+// CreateWebhookResponseDTO is the only response that has the secret, it is
+// needed to check the signature of the deliveries.
+type CreateWebhookResponseDTO struct {
+	Id     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type ReadWebhookResponseDTO struct {
+	Id     string   `json:"id"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	Time   string   `json:"time"`
+}
+
+func NewReadWebhookResponseDTO(webhook model.Webhook) ReadWebhookResponseDTO {
+	return ReadWebhookResponseDTO{
+		Id:     webhook.Id.String(),
+		Url:    webhook.Url,
+		Events: webhook.Events,
+		Time:   webhook.Time,
+	}
+}
+
+type ReadDeliveryResponseDTO struct {
+	Id          string `json:"id"`
+	Event       string `json:"event"`
+	State       string `json:"state"`
+	Attempts    int    `json:"attempts"`
+	NextAttempt string `json:"next_attempt,omitempty"`
+	LastStatus  int    `json:"last_status,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	Time        string `json:"time"`
+}
+
+func NewReadDeliveryResponseDTO(delivery model.Delivery) ReadDeliveryResponseDTO {
+	res := ReadDeliveryResponseDTO{
+		Id:         delivery.Id.String(),
+		Event:      delivery.Event,
+		State:      delivery.State,
+		Attempts:   delivery.Attempts,
+		LastStatus: delivery.LastStatus,
+		LastError:  delivery.LastError,
+		Time:       delivery.Time,
+	}
+
+	if delivery.State == model.DeliveryStatePending {
+		res.NextAttempt = delivery.NextAttempt.Format(time.RFC3339)
+	}
+
+	return res
+}
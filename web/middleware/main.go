@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"slices"
@@ -30,14 +33,23 @@ const (
 	OwnershipUsr = 'U'
 	OwnershipSrv = 'S'
 	OwnershipTrs = 'T'
+	OwnershipBch = 'B'
+	OwnershipWhk = 'W'
+
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyMaxLength = 100
 )
 
 type MiddlewareFactory struct {
-	repo repository.OwnershipRepository
+	repo    repository.OwnershipRepository
+	idmRepo repository.IdempotencyRepository
 }
 
-func NewMiddlewareFactory(repo repository.OwnershipRepository) MiddlewareFactory {
-	return MiddlewareFactory{repo}
+func NewMiddlewareFactory(
+	repo repository.OwnershipRepository,
+	idmRepo repository.IdempotencyRepository,
+) MiddlewareFactory {
+	return MiddlewareFactory{repo, idmRepo}
 }
 
 type Middleware = func(http.Handler) http.Handler
@@ -98,6 +110,10 @@ func (factory *MiddlewareFactory) ClearanceOrOwnership(level int8, entity rune)
 					cerr = factory.repo.CheckServiceOwnership(ctx, resource, user.Id)
 				case OwnershipTrs:
 					cerr = factory.repo.CheckTransactionOwnership(ctx, resource, user.Id)
+				case OwnershipBch:
+					cerr = factory.repo.CheckBatchOwnership(ctx, resource, user.Id)
+				case OwnershipWhk:
+					cerr = factory.repo.CheckWebhookOwnership(ctx, resource, user.Id)
 				default:
 					panic("unknown ownership entity")
 				}
@@ -138,6 +154,114 @@ func (factory *MiddlewareFactory) Logger(next http.Handler) http.Handler {
 	})
 }
 
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Idempotency honors the Idempotency-Key header, the first response for a key
+// is stored and replayed for retries of the same request. Keys are scoped to
+// the authenticated user, so it must come after Auth when the endpoint
+// requires authentication. Keys of anonymous requests only match identical
+// requests.
+func (factory *MiddlewareFactory) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > idempotencyMaxLength {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println("idempotency key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		user, _ := r.Context().Value(userKey).(model.User)
+		req := model.NewIdempotentRequest(
+			user.Id, key, r.Method, r.URL.Path, r.URL.RawQuery, body)
+
+		ctx := r.Context()
+		stored, err := factory.idmRepo.BeginRequest(ctx, req)
+		switch {
+		case errors.Is(err, repository.ErrIdempotencyConflict):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			log.Println(err)
+			return
+		case errors.Is(err, repository.ErrIdempotencyInProgress):
+			w.WriteHeader(http.StatusConflict)
+			log.Println(err)
+			return
+		case err != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+
+		if stored.Completed() {
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Response)
+			return
+		}
+
+		rec := responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(&rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// server errors are not stored so the client can try again
+		if rec.status >= http.StatusInternalServerError {
+			if err := factory.idmRepo.ReleaseRequest(ctx, req); err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		// handlers rarely set the content type, it is sniffed the same way
+		// the server did when the response was written
+		req.ContentType = w.Header().Get("Content-Type")
+		if req.ContentType == "" && rec.body.Len() > 0 {
+			req.ContentType = http.DetectContentType(rec.body.Bytes())
+		}
+
+		req.Status = rec.status
+		req.Response = rec.body.Bytes()
+		if err := factory.idmRepo.CompleteRequest(ctx, req); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
 func Chain(middlewares ...Middleware) Middleware {
 	fn := func(endpoint http.Handler) http.Handler {
 		if len(middlewares) == 0 {